@@ -21,8 +21,11 @@ func TestDependabot(t *testing.T) {
 	})
 
 	ctx := context.Background()
+	conds := scripttest.DefaultConds()
+	// end to end scripts pull real updater images and use public registries, so they're opt in
+	conds["e2e"] = script.BoolCondition("DEPENDABOT_E2E=1", os.Getenv("DEPENDABOT_E2E") == "1")
 	engine := &script.Engine{
-		Conds: scripttest.DefaultConds(),
+		Conds: conds,
 		Cmds:  Commands(),
 		Quiet: !testing.Verbose(),
 	}
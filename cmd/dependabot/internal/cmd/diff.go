@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/dependabot/cli/internal/model"
+	"github.com/dependabot/cli/internal/server"
+)
+
+// dependencyChange is one row of the --diff table
+type dependencyChange struct {
+	name        string
+	previous    string
+	version     string
+	pullRequest string
+}
+
+// dependencyDiff collects the dependency changes from the pull requests the updater would create or update
+type dependencyDiff struct {
+	changes []dependencyChange
+}
+
+func (d *dependencyDiff) observe(call server.APICall) {
+	switch data := call.Data.(type) {
+	case model.CreatePullRequest:
+		for _, dep := range data.Dependencies {
+			version := "removed"
+			if dep.Version != nil && !dep.Removed {
+				version = *dep.Version
+			}
+			d.changes = append(d.changes, dependencyChange{
+				name:        dep.Name,
+				previous:    valueOrDash(dep.PreviousVersion),
+				version:     version,
+				pullRequest: "new",
+			})
+		}
+	case model.UpdatePullRequest:
+		// only the names are sent when updating a pull request
+		for _, name := range data.DependencyNames {
+			d.changes = append(d.changes, dependencyChange{
+				name:        name,
+				previous:    "-",
+				version:     "-",
+				pullRequest: "updated",
+			})
+		}
+	}
+}
+
+func (d *dependencyDiff) print(out io.Writer) {
+	if len(d.changes) == 0 {
+		_, _ = fmt.Fprintln(out, "No dependencies would be updated")
+		return
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "DEPENDENCY\tVERSION\tPULL REQUEST")
+	for _, change := range d.changes {
+		_, _ = fmt.Fprintf(w, "%s\t%s -> %s\t%s\n", change.name, change.previous, change.version, change.pullRequest)
+	}
+	_ = w.Flush()
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dependabot/cli/internal/model"
+	"github.com/dependabot/cli/internal/server"
+)
+
+func Test_dependencyDiff(t *testing.T) {
+	v2 := "2.0.0"
+	var diff dependencyDiff
+	diff.observe(server.APICall{Kind: "update_dependency_list", Data: model.UpdateDependencyList{}})
+	diff.observe(server.APICall{Kind: "create_pull_request", Data: model.CreatePullRequest{
+		Dependencies: []model.Dependency{
+			{Name: "rsc.io/quote", PreviousVersion: "1.5.2", Version: &v2},
+			{Name: "rsc.io/sampler", PreviousVersion: "1.3.0", Removed: true},
+		},
+	}})
+	diff.observe(server.APICall{Kind: "update_pull_request", Data: model.UpdatePullRequest{
+		DependencyNames: []string{"golang.org/x/text"},
+	}})
+
+	var out bytes.Buffer
+	diff.print(&out)
+	want := `DEPENDENCY         VERSION           PULL REQUEST
+rsc.io/quote       1.5.2 -> 2.0.0    new
+rsc.io/sampler     1.3.0 -> removed  new
+golang.org/x/text  - -> -            updated
+`
+	if out.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, out.String())
+	}
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/dependabot/cli/internal/model"
+	"github.com/spf13/cobra"
+)
+
+var lintCmd = NewLintCommand()
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+}
+
+func NewLintCommand() *cobra.Command {
+	var strict bool
+
+	cmd := &cobra.Command{
+		Use:   "lint <scenario.yml>...",
+		Short: "Check scenario files for common mistakes",
+		Example: heredoc.Doc(`
+		    $ dependabot lint go-scenario.yml
+		    $ dependabot lint --strict testdata/*.yml
+	    `),
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			var errorCount int
+			for _, file := range args {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					return fmt.Errorf("failed to open scenario file: %w", err)
+				}
+				findings, err := model.LintScenario(data)
+				if err != nil {
+					return fmt.Errorf("failed to decode %s: %w", file, err)
+				}
+				for _, finding := range findings {
+					if strict {
+						finding.Severity = model.LintError
+					}
+					if finding.Severity == model.LintError {
+						errorCount++
+					}
+					_, _ = fmt.Fprintf(out, "%s:%s\n", file, finding)
+				}
+			}
+			if errorCount > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("found %d errors", errorCount)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&strict, "strict", false, "treat warnings as errors")
+
+	return cmd
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintCommand(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		// mark_as_processed first is only a warning
+		"warning.yml": `input:
+  job:
+    package-manager: go_modules
+    source:
+      repo: rsc/quote
+output:
+  - type: mark_as_processed
+    expect:
+      data:
+        base-commit-sha: "1234"
+  - type: update_dependency_list
+    expect:
+      data:
+        dependencies: []
+        dependency_files: []
+`,
+		"error.yml": `input:
+  job:
+    package-manager: go_modules
+    source:
+      repo: rsc/quote
+    secrity-updates-only: true
+`,
+		"invalid.yml": "input: [\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lint := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		cmd := NewLintCommand()
+		cmd.SetOut(&out)
+		cmd.SetErr(io.Discard)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+	warningFile, errorFile := filepath.Join(dir, "warning.yml"), filepath.Join(dir, "error.yml")
+
+	t.Run("warnings are printed but don't fail", func(t *testing.T) {
+		out, err := lint(warningFile)
+		if err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+		want := warningFile + ":7: warning: mark_as_processed is the last call the updater makes (output-order)\n" +
+			warningFile + ":11: warning: update_dependency_list is the first call the updater makes (output-order)\n"
+		if out != want {
+			t.Errorf("expected:\n%s\ngot:\n%s", want, out)
+		}
+	})
+
+	t.Run("warnings fail with --strict", func(t *testing.T) {
+		out, err := lint("--strict", warningFile)
+		if err == nil || err.Error() != "found 2 errors" {
+			t.Errorf("expected the warnings to be errors, got %v", err)
+		}
+		if !strings.Contains(out, warningFile+":7: error: mark_as_processed is the last call") {
+			t.Errorf("expected the warnings to be printed as errors, got:\n%s", out)
+		}
+	})
+
+	t.Run("errors fail", func(t *testing.T) {
+		out, err := lint(warningFile, errorFile)
+		if err == nil || err.Error() != "found 1 errors" {
+			t.Errorf("expected one error, got %v", err)
+		}
+		want := errorFile + ":6: error: field secrity-updates-only not found in type model.Job (unknown-field)\n"
+		if !strings.HasSuffix(out, want) {
+			t.Errorf("expected every file to be linted, ending with:\n%s\ngot:\n%s", want, out)
+		}
+	})
+
+	t.Run("a file that isn't YAML is an error", func(t *testing.T) {
+		_, err := lint(filepath.Join(dir, "invalid.yml"))
+		if err == nil || !strings.HasPrefix(err.Error(), "failed to decode "+filepath.Join(dir, "invalid.yml")) {
+			t.Errorf("expected a decode error, got %v", err)
+		}
+	})
+
+	t.Run("a missing file is an error", func(t *testing.T) {
+		_, err := lint(filepath.Join(dir, "missing.yml"))
+		if err == nil || !strings.HasPrefix(err.Error(), "failed to open scenario file") {
+			t.Errorf("expected an open error, got %v", err)
+		}
+	})
+
+	t.Run("requires a file", func(t *testing.T) {
+		if _, err := lint(); err == nil {
+			t.Error("expected an error without a file")
+		}
+	})
+}
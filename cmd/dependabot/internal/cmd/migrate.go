@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/dependabot/cli/internal/model"
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = NewMigrateCommand()
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+}
+
+func NewMigrateCommand() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate <scenario.yml>...",
+		Short: "Update scenario files to the current format",
+		Example: heredoc.Doc(`
+		    $ dependabot migrate go-scenario.yml
+		    $ dependabot migrate --dry-run testdata/*.yml
+	    `),
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			for _, file := range args {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					return fmt.Errorf("failed to open scenario file: %w", err)
+				}
+				migrated, applied, err := model.MigrateScenario(data)
+				if err != nil {
+					return fmt.Errorf("failed to migrate %s: %w", file, err)
+				}
+				if len(applied) == 0 || bytes.Equal(data, migrated) {
+					_, _ = fmt.Fprintf(out, "%s: up to date\n", file)
+					continue
+				}
+				for _, m := range applied {
+					_, _ = fmt.Fprintf(out, "%s: %s\n", file, m)
+				}
+				if dryRun {
+					continue
+				}
+				if err = os.WriteFile(file, migrated, 0644); err != nil {
+					return fmt.Errorf("failed to write %s: %w", file, err)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the migrations that would be applied without writing the files")
+
+	return cmd
+}
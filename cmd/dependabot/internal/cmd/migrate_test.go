@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dependabot/cli/internal/model"
+)
+
+func TestMigrateCommand(t *testing.T) {
+	old := `job:
+  package-manager: go_modules
+  source:
+    repo: rsc/quote
+output:
+  - type: mark_as_processed
+    expect:
+      data:
+        base-commit-sha: "1234"
+`
+	migrated := `input:
+  job:
+    package-manager: go_modules
+    source:
+      repo: rsc/quote
+output:
+  - type: mark_as_processed
+    expect:
+      data:
+        base-commit-sha: "1234"
+`
+	writeFile := func(t *testing.T, content string) string {
+		file := filepath.Join(t.TempDir(), "scenario.yml")
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	migrate := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		cmd := NewMigrateCommand()
+		cmd.SetOut(&out)
+		cmd.SetErr(io.Discard)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+	readFile := func(t *testing.T, file string) string {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	t.Run("rewrites the file", func(t *testing.T) {
+		file := writeFile(t, old)
+		out, err := migrate(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := file + ": " + model.Migrations[0].String() + "\n"; out != want {
+			t.Errorf("expected:\n%s\ngot:\n%s", want, out)
+		}
+		if got := readFile(t, file); got != migrated {
+			t.Errorf("expected the file to be migrated:\n%s\ngot:\n%s", migrated, got)
+		}
+
+		out, err = migrate(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := file + ": up to date\n"; out != want {
+			t.Errorf("expected a migrated file to be up to date, got %q", out)
+		}
+	})
+
+	t.Run("--dry-run leaves the file alone", func(t *testing.T) {
+		file := writeFile(t, old)
+		out, err := migrate("--dry-run", file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := file + ": " + model.Migrations[0].String() + "\n"; out != want {
+			t.Errorf("expected:\n%s\ngot:\n%s", want, out)
+		}
+		if got := readFile(t, file); got != old {
+			t.Errorf("expected the file to be unchanged, got:\n%s", got)
+		}
+	})
+
+	t.Run("a file that isn't YAML is an error", func(t *testing.T) {
+		file := writeFile(t, "job: [\n")
+		_, err := migrate(file)
+		if err == nil || !strings.HasPrefix(err.Error(), "failed to migrate "+file) {
+			t.Errorf("expected a migrate error, got %v", err)
+		}
+	})
+
+	t.Run("a missing file is an error", func(t *testing.T) {
+		_, err := migrate(filepath.Join(t.TempDir(), "missing.yml"))
+		if err == nil || !strings.HasPrefix(err.Error(), "failed to open scenario file") {
+			t.Errorf("expected an open error, got %v", err)
+		}
+	})
+
+	t.Run("requires a file", func(t *testing.T) {
+		if _, err := migrate(); err == nil {
+			t.Error("expected an error without a file")
+		}
+	})
+}
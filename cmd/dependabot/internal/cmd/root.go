@@ -26,6 +26,9 @@ type SharedFlags struct {
 	local               string
 }
 
+// exitCodeTimeout is the exit code when an update runs longer than --timeout, so CI can tell a hang from a failure
+const exitCodeTimeout = 2
+
 // root flags
 var (
 	updaterImage   string
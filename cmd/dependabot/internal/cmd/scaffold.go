@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/dependabot/cli/internal/model"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// githubAPIURL is where scaffold looks up repositories, a variable so tests can use a fake
+var githubAPIURL = "https://api.github.com"
+
+// dependencyFileNames are the manifests and lockfiles scaffold looks for to check the package manager is right
+var dependencyFileNames = map[string][]string{
+	"bundler":      {"Gemfile", "Gemfile.lock"},
+	"cargo":        {"Cargo.toml", "Cargo.lock"},
+	"composer":     {"composer.json", "composer.lock"},
+	"docker":       {"Dockerfile"},
+	"go_modules":   {"go.mod", "go.sum"},
+	"gradle":       {"build.gradle", "build.gradle.kts", "settings.gradle", "settings.gradle.kts"},
+	"hex":          {"mix.exs", "mix.lock"},
+	"maven":        {"pom.xml"},
+	"npm_and_yarn": {"package.json", "package-lock.json", "yarn.lock", "pnpm-lock.yaml"},
+	"pip":          {"requirements.txt", "Pipfile", "Pipfile.lock", "pyproject.toml", "setup.py", "poetry.lock"},
+	"pub":          {"pubspec.yaml", "pubspec.lock"},
+	"swift":        {"Package.swift", "Package.resolved"},
+}
+
+var scaffoldCmd = NewScaffoldCommand()
+
+func init() {
+	rootCmd.AddCommand(scaffoldCmd)
+}
+
+func NewScaffoldCommand() *cobra.Command {
+	var directory, branch, output string
+
+	cmd := &cobra.Command{
+		Use:   "scaffold <package_manager> <repo>",
+		Short: "Generate a scenario file for a GitHub repository",
+		Long: heredoc.Doc(`
+		    Generate a scenario file with the job for a GitHub repository filled in,
+		    including the commit to update, as a starting point for a test.
+		    Set LOCAL_GITHUB_ACCESS_TOKEN to look up private repositories.
+	    `),
+		Example: heredoc.Doc(`
+		    $ dependabot scaffold go_modules rsc/quote
+		    $ dependabot scaffold npm_and_yarn https://github.com/dependabot/smoke-tests -d /npm -o npm.yml
+	    `),
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			packageManager := args[0]
+			repo, err := parseGitHubRepo(args[1])
+			if err != nil {
+				return err
+			}
+
+			client := githubClient{baseURL: githubAPIURL, token: os.Getenv("LOCAL_GITHUB_ACCESS_TOKEN")}
+			ref := branch
+			if ref == "" {
+				ref = "HEAD"
+			}
+			commit, err := client.commitSHA(cmd.Context(), repo, ref)
+			if err != nil {
+				return err
+			}
+			files, err := client.directoryFiles(cmd.Context(), repo, directory, commit)
+			if err != nil {
+				return err
+			}
+			if names, ok := dependencyFileNames[packageManager]; ok && !slices.ContainsFunc(files, func(file string) bool {
+				return slices.Contains(names, file)
+			}) {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "warning: none of %s are in %s of %s, check the package manager and directory\n",
+					strings.Join(names, ", "), directory, repo)
+			}
+
+			scenario := model.Scenario{Input: model.Input{Job: model.Job{
+				PackageManager: packageManager,
+				AllowedUpdates: []model.Allowed{{UpdateType: "all"}},
+				Source: model.Source{
+					Provider:  "github",
+					Repo:      repo,
+					Directory: directory,
+					Branch:    branch,
+					Commit:    commit,
+				},
+			}}}
+			data, err := yaml.Marshal(scenario)
+			if err != nil {
+				return fmt.Errorf("failed to encode scenario: %w", err)
+			}
+			if output == "" {
+				_, err = cmd.OutOrStdout().Write(data)
+				return err
+			}
+			if err = os.WriteFile(output, data, 0666); err != nil {
+				return fmt.Errorf("failed to write scenario file: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&directory, "directory", "d", "/", "directory of the dependency files in the repo")
+	cmd.Flags().StringVar(&branch, "branch", "", "branch to update, the default branch if not set")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write the scenario to a file instead of stdout")
+
+	return cmd
+}
+
+// parseGitHubRepo accepts owner/name or a URL of the repo, returning owner/name
+func parseGitHubRepo(arg string) (string, error) {
+	repo := arg
+	if strings.Contains(arg, "://") {
+		u, err := url.Parse(arg)
+		if err != nil {
+			return "", fmt.Errorf("invalid repo URL: %w", err)
+		}
+		if u.Host != "github.com" {
+			return "", fmt.Errorf("repo URL %s isn't on github.com", arg)
+		}
+		repo = u.Path
+	}
+	repo = strings.TrimSuffix(strings.Trim(repo, "/"), ".git")
+	if owner, name, ok := strings.Cut(repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return "", fmt.Errorf("repo must be owner/name or a GitHub URL, got %q", arg)
+	}
+	return repo, nil
+}
+
+// githubClient makes the few GitHub API requests scaffold needs
+type githubClient struct {
+	baseURL string
+	token   string
+}
+
+// commitSHA resolves a branch, or HEAD for the default branch, to the SHA of its latest commit
+func (c githubClient) commitSHA(ctx context.Context, repo, ref string) (string, error) {
+	var commit struct {
+		SHA string `json:"sha"`
+	}
+	if err := c.get(ctx, fmt.Sprintf("/repos/%s/commits/%s", repo, url.PathEscape(ref)), &commit); err != nil {
+		return "", fmt.Errorf("failed to look up the commit of %s in %s: %w", ref, repo, err)
+	}
+	return commit.SHA, nil
+}
+
+// directoryFiles lists the names of the files in a directory of the repo at commit
+func (c githubClient) directoryFiles(ctx context.Context, repo, directory, commit string) ([]string, error) {
+	var entries []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+	contentsPath := path.Join("/repos", repo, "contents", strings.Trim(directory, "/"))
+	if err := c.get(ctx, contentsPath+"?ref="+url.QueryEscape(commit), &entries); err != nil {
+		return nil, fmt.Errorf("failed to list %s in %s: %w", directory, repo, err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.Type == "file" {
+			files = append(files, entry.Name)
+		}
+	}
+	return files, nil
+}
+
+func (c githubClient) get(ctx context.Context, apiPath string, out any) error {
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+apiPath, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed creating request: %w", err)
+	}
+	r.Header.Set("Accept", "application/vnd.github+json")
+	r.Header.Set("User-Agent", "dependabot-cli")
+	if c.token != "" {
+		r.Header.Set("Authorization", "token "+c.token)
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return fmt.Errorf("failed making request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errors.New("not found, set LOCAL_GITHUB_ACCESS_TOKEN if the repo is private")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response from the GitHub API: %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
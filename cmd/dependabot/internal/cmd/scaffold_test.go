@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScaffoldCommand(t *testing.T) {
+	var requests []string
+	fakeGitHub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/repos/dependabot/smoke-tests/commits/HEAD":
+			_, _ = w.Write([]byte(`{"sha":"832e37c1a7a4ef89feb9dc7cfa06f62205191994"}`))
+		case "/repos/dependabot/smoke-tests/contents/npm":
+			_, _ = w.Write([]byte(`[{"name":"package.json","type":"file"},{"name":"src","type":"dir"}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer fakeGitHub.Close()
+	defer func(url string) { githubAPIURL = url }(githubAPIURL)
+	githubAPIURL = fakeGitHub.URL
+
+	t.Run("generates the job", func(t *testing.T) {
+		var out, stderr bytes.Buffer
+		cmd := NewScaffoldCommand()
+		cmd.SetOut(&out)
+		cmd.SetErr(&stderr)
+		cmd.SetArgs([]string{"npm_and_yarn", "https://github.com/dependabot/smoke-tests.git", "-d", "/npm"})
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+
+		want := `input:
+    job:
+        package-manager: npm_and_yarn
+        allowed-updates:
+            - update-type: all
+        source:
+            provider: github
+            repo: dependabot/smoke-tests
+            directory: /npm
+            commit: 832e37c1a7a4ef89feb9dc7cfa06f62205191994
+`
+		if out.String() != want {
+			t.Errorf("expected:\n%s\ngot:\n%s", want, out.String())
+		}
+		if stderr.Len() != 0 {
+			t.Errorf("expected no warnings, got %q", stderr.String())
+		}
+		if requests[1] != "/repos/dependabot/smoke-tests/contents/npm?ref=832e37c1a7a4ef89feb9dc7cfa06f62205191994" {
+			t.Errorf("expected the directory to be listed at the commit, got %v", requests)
+		}
+	})
+
+	t.Run("warns when there are no dependency files", func(t *testing.T) {
+		var stderr bytes.Buffer
+		cmd := NewScaffoldCommand()
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&stderr)
+		cmd.SetArgs([]string{"go_modules", "dependabot/smoke-tests", "-d", "/npm"})
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(stderr.String(), "warning: none of go.mod, go.sum are in /npm of dependabot/smoke-tests") {
+			t.Errorf("expected a warning, got %q", stderr.String())
+		}
+	})
+
+	t.Run("unknown repo", func(t *testing.T) {
+		cmd := NewScaffoldCommand()
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs([]string{"go_modules", "dependabot/missing"})
+		err := cmd.Execute()
+		if err == nil || !strings.Contains(err.Error(), "failed to look up the commit of HEAD in dependabot/missing: not found") {
+			t.Errorf("expected a not found error, got %v", err)
+		}
+	})
+}
+
+func Test_parseGitHubRepo(t *testing.T) {
+	for arg, want := range map[string]string{
+		"rsc/quote":                        "rsc/quote",
+		"https://github.com/rsc/quote":     "rsc/quote",
+		"https://github.com/rsc/quote.git": "rsc/quote",
+		"https://github.com/rsc/quote/":    "rsc/quote",
+	} {
+		if got, err := parseGitHubRepo(arg); err != nil || got != want {
+			t.Errorf("expected %s for %s, got %s %v", want, arg, got, err)
+		}
+	}
+	for _, arg := range []string{"quote", "https://gitlab.com/rsc/quote", "https://github.com/rsc/quote/tree/main", "/quote"} {
+		if _, err := parseGitHubRepo(arg); err == nil {
+			t.Errorf("expected %s to be invalid", arg)
+		}
+	}
+}
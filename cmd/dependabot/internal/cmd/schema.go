@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/dependabot/cli/internal/model"
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = NewSchemaCommand()
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+}
+
+func NewSchemaCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema for scenario files",
+		Example: heredoc.Doc(`
+		    $ dependabot schema > scenario.schema.json
+	    `),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := json.MarshalIndent(model.ScenarioSchema(), "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode schema: %w", err)
+			}
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			return err
+		},
+	}
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/dependabot/cli/internal/model"
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = NewStatsCommand()
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+}
+
+func NewStatsCommand() *cobra.Command {
+	var outputFormat string
+
+	cmd := &cobra.Command{
+		Use:   "stats <dir>",
+		Short: "Report what the scenario files in a directory cover",
+		Example: heredoc.Doc(`
+		    $ dependabot stats testdata
+		    $ dependabot stats --output-format json testdata
+	    `),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if outputFormat != "table" && outputFormat != "json" {
+				return fmt.Errorf("--output-format must be table or json, got %q", outputFormat)
+			}
+			files, err := findScenarioFiles(args[0])
+			if err != nil {
+				return err
+			}
+			if len(files) == 0 {
+				return fmt.Errorf("no scenario files found in %s", args[0])
+			}
+
+			var scenarios []model.Scenario
+			for _, file := range files {
+				scenario, _, err := readScenarioFile(file, nil)
+				if err != nil {
+					return fmt.Errorf("%s: %w", file, err)
+				}
+				scenarios = append(scenarios, *scenario)
+			}
+
+			stats := collectStats(scenarios)
+			if outputFormat == "json" {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(stats)
+			}
+			return printStats(cmd.OutOrStdout(), stats)
+		},
+	}
+
+	cmd.Flags().StringVar(&outputFormat, "output-format", "table", "format of the report, table or json")
+
+	return cmd
+}
+
+// scenarioStats is what a set of scenario files covers
+type scenarioStats struct {
+	Scenarios int `json:"scenarios"`
+	// AverageOutputs is the mean number of outputs each scenario expects, across all of its runs
+	AverageOutputs float64 `json:"average_outputs"`
+	// OutputTypes is the number of scenarios that expect each type of output at least once
+	OutputTypes map[string]int `json:"output_types"`
+	// PackageManagers is the number of scenarios that run each package manager
+	PackageManagers map[string]int `json:"package_managers"`
+	// Uncovered are the output types no scenario expects
+	Uncovered []string `json:"uncovered"`
+}
+
+func collectStats(scenarios []model.Scenario) scenarioStats {
+	stats := scenarioStats{
+		Scenarios:       len(scenarios),
+		OutputTypes:     map[string]int{},
+		PackageManagers: map[string]int{},
+		Uncovered:       []string{},
+	}
+	var outputs int
+	for _, scenario := range scenarios {
+		var outputTypes, packageManagers []string
+		for _, run := range scenario.AllRuns() {
+			outputs += len(run.Output)
+			if pm := run.Input.Job.PackageManager; pm != "" && !slices.Contains(packageManagers, pm) {
+				packageManagers = append(packageManagers, pm)
+			}
+			for _, output := range run.Output {
+				if output.Type != "" && !slices.Contains(outputTypes, output.Type) {
+					outputTypes = append(outputTypes, output.Type)
+				}
+			}
+		}
+		// counted once per scenario however many runs or outputs have them
+		for _, outputType := range outputTypes {
+			stats.OutputTypes[outputType]++
+		}
+		for _, pm := range packageManagers {
+			stats.PackageManagers[pm]++
+		}
+	}
+	if len(scenarios) > 0 {
+		stats.AverageOutputs = float64(outputs) / float64(len(scenarios))
+	}
+	for _, outputType := range model.OutputTypes() {
+		if stats.OutputTypes[outputType] == 0 {
+			stats.Uncovered = append(stats.Uncovered, outputType)
+		}
+	}
+	return stats
+}
+
+func printStats(out io.Writer, stats scenarioStats) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "SCENARIOS\t%d\n", stats.Scenarios)
+	_, _ = fmt.Fprintf(w, "AVERAGE OUTPUTS\t%.1f\n", stats.AverageOutputs)
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "OUTPUT TYPE\tSCENARIOS")
+	for _, outputType := range sortedKeys(stats.OutputTypes) {
+		_, _ = fmt.Fprintf(w, "%s\t%d\n", outputType, stats.OutputTypes[outputType])
+	}
+	for _, outputType := range stats.Uncovered {
+		_, _ = fmt.Fprintf(w, "%s\t0\n", outputType)
+	}
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "PACKAGE MANAGER\tSCENARIOS")
+	for _, pm := range sortedKeys(stats.PackageManagers) {
+		_, _ = fmt.Fprintf(w, "%s\t%d\n", pm, stats.PackageManagers[pm])
+	}
+	return w.Flush()
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStatsCommand(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"npm.yml": `input:
+  job:
+    package-manager: npm_and_yarn
+output:
+  - type: update_dependency_list
+    expect:
+      data: {}
+  - type: create_pull_request
+    expect:
+      data: {}
+  - type: mark_as_processed
+    expect:
+      data: {}
+`,
+		"go.yml": `input:
+  job:
+    package-manager: go_modules
+output:
+  - type: update_dependency_list
+    expect:
+      data: {}
+  - type: update_dependency_list
+    expect:
+      data: {}
+  - type: mark_as_processed
+    expect:
+      data: {}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("table", func(t *testing.T) {
+		var out bytes.Buffer
+		cmd := NewStatsCommand()
+		cmd.SetOut(&out)
+		cmd.SetArgs([]string{dir})
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			"SCENARIOS        2\n",
+			"AVERAGE OUTPUTS  3.0\n",
+			"update_dependency_list           2\n",
+			"create_pull_request              1\n",
+			"close_pull_request               0\n",
+			"go_modules       1\n",
+		} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("expected %q in:\n%s", want, out.String())
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var out bytes.Buffer
+		cmd := NewStatsCommand()
+		cmd.SetOut(&out)
+		cmd.SetArgs([]string{"--output-format", "json", dir})
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		var stats scenarioStats
+		if err := json.Unmarshal(out.Bytes(), &stats); err != nil {
+			t.Fatal(err)
+		}
+		if stats.Scenarios != 2 || stats.AverageOutputs != 3 {
+			t.Errorf("expected 2 scenarios averaging 3 outputs, got %+v", stats)
+		}
+		if stats.OutputTypes["update_dependency_list"] != 2 || stats.OutputTypes["create_pull_request"] != 1 {
+			t.Errorf("unexpected output types %v", stats.OutputTypes)
+		}
+		if stats.PackageManagers["npm_and_yarn"] != 1 || stats.PackageManagers["go_modules"] != 1 {
+			t.Errorf("unexpected package managers %v", stats.PackageManagers)
+		}
+		if !slices.Contains(stats.Uncovered, "close_pull_request") || slices.Contains(stats.Uncovered, "mark_as_processed") {
+			t.Errorf("unexpected uncovered output types %v", stats.Uncovered)
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		cmd := NewStatsCommand()
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs([]string{"--output-format", "csv", dir})
+		if err := cmd.Execute(); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
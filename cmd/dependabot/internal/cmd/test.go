@@ -1,13 +1,25 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"text/template"
+	"time"
 
 	"github.com/dependabot/cli/internal/infra"
 	"github.com/dependabot/cli/internal/model"
+	"github.com/dependabot/cli/internal/server"
 
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
@@ -16,44 +28,62 @@ import (
 // local variable for testing
 var executeTestJob = infra.Run
 
+type TestFlags struct {
+	SharedFlags
+	scenarioDir string
+	vars        map[string]string
+	parallel    int
+	failFast    bool
+	verbose     bool
+	// strictOptionals is false to only compare the optional fields an expectation sets
+	strictOptionals bool
+	// strictErrorTypes fails on an error type that isn't in model.KnownErrorTypes instead of warning
+	strictErrorTypes bool
+	// branchPatterns are the regular expressions the branch-name of create_pull_request has to match one of
+	branchPatterns  []string
+	allowedBranches []*regexp.Regexp
+	// updateSnapshots writes the actual output back to scenario files that fail, set by UPDATE_SNAPSHOTS=1
+	updateSnapshots bool
+}
+
 func NewTestCommand() *cobra.Command {
-	var flags SharedFlags
+	var flags TestFlags
 
 	cmd := &cobra.Command{
-		Use:   "test -f <scenario.yml>",
+		Use:   "test [-f <scenario.yml> | --scenario-dir <dir>]",
 		Short: "Test scenarios",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if flags.parallel < 1 {
+				return fmt.Errorf("--parallel must be at least 1")
+			}
+			flags.allowedBranches = nil
+			for _, pattern := range flags.branchPatterns {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					return fmt.Errorf("invalid --branch-pattern %q: %w", pattern, err)
+				}
+				flags.allowedBranches = append(flags.allowedBranches, re)
+			}
+			flags.updateSnapshots = os.Getenv("UPDATE_SNAPSHOTS") == "1"
+			if flags.updateSnapshots && (flags.output != "" || len(flags.vars) > 0) {
+				return fmt.Errorf("UPDATE_SNAPSHOTS=1 can't be used with --output or --var")
+			}
+			if flags.scenarioDir != "" {
+				if flags.file != "" || flags.output != "" {
+					return fmt.Errorf("--scenario-dir can't be used with --file or --output")
+				}
+				return runScenarioDir(cmd.OutOrStdout(), cmd.ErrOrStderr(), &flags)
+			}
 			if flags.file == "" {
 				return fmt.Errorf("requires a scenario file")
 			}
 
-			scenario, inputRaw, err := readScenarioFile(flags.file)
+			scenario, inputRaw, err := loadScenario(flags.file, flags.vars)
 			if err != nil {
 				return err
 			}
 
-			processInput(&scenario.Input, nil)
-
-			if err := executeTestJob(infra.RunParams{
-				CacheDir:            flags.cache,
-				CollectorConfigPath: flags.collectorConfigPath,
-				CollectorImage:      collectorImage,
-				Creds:               scenario.Input.Credentials,
-				Debug:               flags.debugging,
-				Expected:            scenario.Output,
-				ExtraHosts:          flags.extraHosts,
-				InputName:           flags.file,
-				InputRaw:            inputRaw,
-				Job:                 &scenario.Input.Job,
-				LocalDir:            flags.local,
-				Output:              flags.output,
-				ProxyCertPath:       flags.proxyCertPath,
-				ProxyImage:          proxyImage,
-				PullImages:          flags.pullImages,
-				Timeout:             flags.timeout,
-				UpdaterImage:        updaterImage,
-				Volumes:             flags.volumes,
-			}); err != nil {
+			if err := runScenario(&flags, flags.file, scenario, inputRaw, os.Stdout, os.Stderr); err != nil {
 				log.Fatal(err)
 			}
 
@@ -62,6 +92,14 @@ func NewTestCommand() *cobra.Command {
 	}
 
 	cmd.Flags().StringVarP(&flags.file, "file", "f", "", "path to scenario file")
+	cmd.Flags().StringVar(&flags.scenarioDir, "scenario-dir", "", "run every scenario file in a directory")
+	cmd.Flags().IntVar(&flags.parallel, "parallel", 1, "number of scenarios in --scenario-dir to run at once")
+	cmd.Flags().BoolVar(&flags.failFast, "fail-fast", false, "stop running scenarios in --scenario-dir after the first one fails")
+	cmd.Flags().StringToStringVar(&flags.vars, "var", nil, "render the scenario as a template with key=value")
+	cmd.Flags().BoolVar(&flags.verbose, "verbose", false, "print each expectation as it's checked")
+	cmd.Flags().BoolVar(&flags.strictOptionals, "strict-optionals", true, "require optional fields an expectation leaves out to be empty")
+	cmd.Flags().BoolVar(&flags.strictErrorTypes, "strict-error-types", false, "fail on unknown error types in record_update_job_error")
+	cmd.Flags().StringArrayVar(&flags.branchPatterns, "branch-pattern", nil, "regular expression the branch-name of create_pull_request must match, can be repeated")
 
 	cmd.Flags().StringVarP(&flags.output, "output", "o", "", "write scenario to file")
 	cmd.Flags().StringVar(&flags.cache, "cache", "", "cache import/export directory")
@@ -79,15 +117,303 @@ func NewTestCommand() *cobra.Command {
 
 var testCmd = NewTestCommand()
 
-func readScenarioFile(file string) (*model.Scenario, []byte, error) {
+// loadScenario reads and validates a scenario file, rendering it as a template first if there are any vars
+func loadScenario(file string, vars map[string]string) (*model.Scenario, []byte, error) {
+	scenario, inputRaw, err := readScenarioFile(file, vars)
+	if err != nil {
+		return nil, nil, err
+	}
+	if errs := model.ValidateScenario(*scenario); len(errs) > 0 {
+		return nil, nil, fmt.Errorf("invalid scenario file %s: %w", file, errors.Join(errs...))
+	}
+	return scenario, inputRaw, nil
+}
+
+// runScenario runs each run of the scenario, writing the progress of the expectations to progress and the
+// container logs and diffs to logs
+func runScenario(flags *TestFlags, file string, scenario *model.Scenario, inputRaw []byte, progress, logs io.Writer) error {
+	runs := scenario.AllRuns()
+	if len(runs) > 1 && flags.output != "" {
+		return fmt.Errorf("--output can't be used with a scenario that has multiple runs")
+	}
+
+	var errs []error
+	var actualRuns []model.Run
+	var updated bool
+	for i := range runs {
+		run := &runs[i]
+		inputName, runRaw := file, inputRaw
+		if len(scenario.Runs) > 0 {
+			// diff each run on its own since the output only covers one run
+			inputName = fmt.Sprintf("%s (run %d)", file, i+1)
+			data, err := yaml.Marshal(model.Scenario{Input: run.Input, Output: run.Output})
+			if err != nil {
+				return fmt.Errorf("failed to marshal run %d: %w", i+1, err)
+			}
+			runRaw = data
+		}
+
+		processInput(&run.Input, nil)
+
+		output := flags.output
+		if flags.updateSnapshots {
+			snapshot, err := os.CreateTemp("", "snapshot-*.yml")
+			if err != nil {
+				return fmt.Errorf("failed to create snapshot file: %w", err)
+			}
+			_ = snapshot.Close()
+			defer os.Remove(snapshot.Name())
+			output = snapshot.Name()
+		}
+
+		var apiOptions []server.APIOption
+		if flags.verbose {
+			apiOptions = append(apiOptions, server.WithProgress(progress))
+		}
+		if !flags.strictOptionals {
+			apiOptions = append(apiOptions, server.WithStrictOptionals(false))
+		}
+		if flags.strictErrorTypes {
+			apiOptions = append(apiOptions, server.WithStrictErrorTypes())
+		}
+		if len(flags.allowedBranches) > 0 {
+			apiOptions = append(apiOptions, server.WithBranchPatterns(flags.allowedBranches...))
+		}
+
+		err := executeTestJob(infra.RunParams{
+			APIOptions:          apiOptions,
+			CacheDir:            flags.cache,
+			CollectorConfigPath: flags.collectorConfigPath,
+			CollectorImage:      collectorImage,
+			Creds:               run.Input.Credentials,
+			Debug:               flags.debugging,
+			Expected:            run.Output,
+			ExtraHosts:          flags.extraHosts,
+			InputName:           inputName,
+			InputRaw:            runRaw,
+			Job:                 &run.Input.Job,
+			LocalDir:            flags.local,
+			LogWriter:           logs,
+			Output:              output,
+			ProxyCertPath:       flags.proxyCertPath,
+			ProxyImage:          proxyImage,
+			PullImages:          flags.pullImages,
+			Timeout:             flags.timeout,
+			UpdaterImage:        updaterImage,
+			Volumes:             flags.volumes,
+		})
+		if flags.updateSnapshots && (err == nil || errors.Is(err, infra.ErrExpectationsFailed)) {
+			actual, readErr := readSnapshot(output)
+			if readErr != nil {
+				return fmt.Errorf("%s: %w", inputName, readErr)
+			}
+			actualRuns = append(actualRuns, model.Run{Input: actual.Input, Output: actual.Output})
+			updated = updated || err != nil
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", inputName, err))
+		}
+	}
+	if len(errs) > 0 || !updated {
+		return errors.Join(errs...)
+	}
+	return writeSnapshot(logs, file, scenario, actualRuns)
+}
+
+func readSnapshot(file string) (*model.Scenario, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var actual model.Scenario
+	if err = yaml.Unmarshal(data, &actual); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	return &actual, nil
+}
+
+// writeSnapshot replaces the scenario file with what the updater actually did, keeping its layout of runs and its format
+func writeSnapshot(logs io.Writer, file string, scenario *model.Scenario, actualRuns []model.Run) error {
+	actual := model.Scenario{Input: actualRuns[0].Input, Output: actualRuns[0].Output}
+	if len(scenario.Runs) > 0 {
+		actual = model.Scenario{Runs: actualRuns}
+	}
+	var data []byte
+	var err error
+	if filepath.Ext(file) == ".toml" {
+		data, err = model.MarshalTOML(actual)
+	} else {
+		data, err = yaml.Marshal(actual)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot for %s: %w", file, err)
+	}
+	if err = os.WriteFile(file, data, 0666); err != nil {
+		return fmt.Errorf("failed to update snapshot: %w", err)
+	}
+	_, _ = fmt.Fprintf(logs, "updated snapshot %s\n", file)
+	return nil
+}
+
+type scenarioResult struct {
+	file string
+	err  error
+	// skipped is set when --fail-fast stopped the scenario from running
+	skipped bool
+}
+
+// runScenarioDir runs every scenario file under flags.scenarioDir and prints a summary
+// runScenarioDir runs every scenario in the directory and writes a summary of the results to out. With
+// --parallel each scenario's progress and logs are written to errOut together once it finishes, so the
+// lines of scenarios running at the same time aren't mixed up.
+func runScenarioDir(out, errOut io.Writer, flags *TestFlags) error {
+	files, err := findScenarioFiles(flags.scenarioDir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no scenario files found in %s", flags.scenarioDir)
+	}
+
+	// each run starts its own API on its own port, so scenarios can run side by side
+	results := make([]scenarioResult, len(files))
+	sem := make(chan struct{}, flags.parallel)
+	var wg sync.WaitGroup
+	var failed atomic.Bool
+	var errOutMu sync.Mutex
+	for i, file := range files {
+		sem <- struct{}{}
+		if flags.failFast && failed.Load() {
+			// scenarios already running are left to finish
+			<-sem
+			results[i] = scenarioResult{file: file, skipped: true}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			var progress, logs io.Writer = os.Stdout, errOut
+			var buffered *syncBuffer
+			if flags.parallel > 1 {
+				buffered = &syncBuffer{}
+				progress, logs = buffered, buffered
+			}
+			scenario, inputRaw, err := loadScenario(file, flags.vars)
+			if err == nil {
+				err = runScenario(flags, file, scenario, inputRaw, progress, logs)
+			}
+			if buffered != nil {
+				errOutMu.Lock()
+				_, _ = buffered.WriteTo(errOut)
+				errOutMu.Unlock()
+			}
+			if err != nil {
+				failed.Store(true)
+			}
+			// stored by index so the results are in file order however long each one takes
+			results[i] = scenarioResult{file: file, err: err}
+		}()
+	}
+	wg.Wait()
+
+	return printScenarioResults(out, results)
+}
+
+// syncBuffer collects a scenario's output, which the API and the container logs write at the same time
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) WriteTo(w io.Writer) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.WriteTo(w)
+}
+
+func findScenarioFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ext := filepath.Ext(path); !d.IsDir() && (ext == ".yaml" || ext == ".yml" || ext == ".toml") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find scenario files: %w", err)
+	}
+	return files, nil
+}
+
+func printScenarioResults(out io.Writer, results []scenarioResult) error {
+	var failed, skipped int
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "RESULT\tSCENARIO")
+	for _, result := range results {
+		status := "PASS"
+		if result.skipped {
+			status = "SKIP"
+			skipped++
+		} else if result.err != nil {
+			status = "FAIL"
+			failed++
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", status, result.file)
+	}
+	_ = w.Flush()
+
+	for _, result := range results {
+		if result.err != nil {
+			_, _ = fmt.Fprintf(out, "\n--- FAIL: %s\n%v\n", result.file, result.err)
+		}
+	}
+
+	if failed > 0 && skipped > 0 {
+		return fmt.Errorf("%d of %d scenarios failed, %d were skipped by --fail-fast", failed, len(results), skipped)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d scenarios failed", failed, len(results))
+	}
+	return nil
+}
+
+func readScenarioFile(file string, vars map[string]string) (*model.Scenario, []byte, error) {
 	var scenario model.Scenario
 
 	data, err := os.ReadFile(file)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to open scenario file: %w", err)
 	}
+	if len(vars) > 0 {
+		if data, err = renderScenario(file, data, vars); err != nil {
+			return nil, nil, err
+		}
+	}
+	if filepath.Ext(file) == ".toml" {
+		// converted so the rest is the same as a YAML file, including the diff of the expectations
+		if data, err = model.TOMLToYAML(data); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode scenario file: %w", err)
+		}
+	}
 	if err = json.Unmarshal(data, &scenario); err != nil {
-		if err = yaml.Unmarshal(data, &scenario); err != nil {
+		expandEnv := model.ExpandEnv(os.LookupEnv, func(warning string) {
+			log.Printf("%s: %s", file, warning)
+		})
+		// anchors are resolved like lint does, so every command reads the same scenario
+		if err = model.UnmarshalWithIncludes(data, file, &scenario, model.ResolveAnchors, expandEnv); err != nil {
 			return nil, nil, fmt.Errorf("failed to decode scenario file: %w", err)
 		}
 	}
@@ -95,6 +421,26 @@ func readScenarioFile(file string) (*model.Scenario, []byte, error) {
 	return &scenario, data, nil
 }
 
+// renderScenario executes the scenario file as a text/template with the vars and built-ins like {{ .now }}.
+// It's only done when vars are given since scenario files can contain {{ }} in other contexts, e.g. GitHub Actions.
+func renderScenario(file string, data []byte, vars map[string]string) ([]byte, error) {
+	tmpl, err := template.New(filepath.Base(file)).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse scenario template: %w", err)
+	}
+	values := map[string]string{
+		"now": time.Now().UTC().Format(time.RFC3339),
+	}
+	for k, v := range vars {
+		values[k] = v
+	}
+	var rendered bytes.Buffer
+	if err = tmpl.Execute(&rendered, values); err != nil {
+		return nil, fmt.Errorf("failed to render scenario template: %w", err)
+	}
+	return rendered.Bytes(), nil
+}
+
 func init() {
 	rootCmd.AddCommand(testCmd)
 }
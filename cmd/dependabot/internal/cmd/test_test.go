@@ -1,8 +1,19 @@
 package cmd
 
 import (
-	"github.com/dependabot/cli/internal/infra"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/dependabot/cli/internal/infra"
+	"github.com/dependabot/cli/internal/model"
 )
 
 func TestTestCommand(t *testing.T) {
@@ -34,5 +45,535 @@ func TestTestCommand(t *testing.T) {
 		if actualParams.Job.PackageManager != "go_modules" {
 			t.Errorf("expected package manager to be set")
 		}
+		if len(actualParams.APIOptions) != 0 {
+			t.Errorf("expected no API options by default")
+		}
+	})
+
+	t.Run("Print progress with --verbose", func(t *testing.T) {
+		var actualParams *infra.RunParams
+		executeTestJob = func(params infra.RunParams) error {
+			actualParams = &params
+			return nil
+		}
+		cmd := NewTestCommand()
+		if err := cmd.ParseFlags([]string{"-f", "../../../../testdata/scenario.yml", "--verbose"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.RunE(cmd, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if actualParams == nil || len(actualParams.APIOptions) != 1 {
+			t.Errorf("expected the progress option to be passed to the API")
+		}
+	})
+
+	t.Run("Ignore unset optional fields with --strict-optionals=false", func(t *testing.T) {
+		var actualParams *infra.RunParams
+		executeTestJob = func(params infra.RunParams) error {
+			actualParams = &params
+			return nil
+		}
+		cmd := NewTestCommand()
+		if err := cmd.ParseFlags([]string{"-f", "../../../../testdata/scenario.yml", "--strict-optionals=false"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.RunE(cmd, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if actualParams == nil || len(actualParams.APIOptions) != 1 {
+			t.Errorf("expected the strict optionals option to be passed to the API")
+		}
+	})
+
+	t.Run("Fail on unknown error types with --strict-error-types", func(t *testing.T) {
+		var actualParams *infra.RunParams
+		executeTestJob = func(params infra.RunParams) error {
+			actualParams = &params
+			return nil
+		}
+		cmd := NewTestCommand()
+		if err := cmd.ParseFlags([]string{"-f", "../../../../testdata/scenario.yml", "--strict-error-types"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.RunE(cmd, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if actualParams == nil || len(actualParams.APIOptions) != 1 {
+			t.Errorf("expected the strict error types option to be passed to the API")
+		}
+	})
+
+	t.Run("Require branch names to match --branch-pattern", func(t *testing.T) {
+		var actualParams *infra.RunParams
+		executeTestJob = func(params infra.RunParams) error {
+			actualParams = &params
+			return nil
+		}
+		cmd := NewTestCommand()
+		if err := cmd.ParseFlags([]string{"-f", "../../../../testdata/scenario.yml", "--branch-pattern", "^dependabot/", "--branch-pattern", "^deps-"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.RunE(cmd, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if actualParams == nil || len(actualParams.APIOptions) != 1 {
+			t.Errorf("expected the branch patterns option to be passed to the API")
+		}
+	})
+
+	t.Run("Reject an invalid --branch-pattern", func(t *testing.T) {
+		executeTestJob = func(params infra.RunParams) error {
+			t.Fatal("expected the scenario not to run")
+			return nil
+		}
+		cmd := NewTestCommand()
+		if err := cmd.ParseFlags([]string{"-f", "../../../../testdata/scenario.yml", "--branch-pattern", "dependabot/["}); err != nil {
+			t.Fatal(err)
+		}
+		err := cmd.RunE(cmd, nil)
+		if err == nil || !strings.HasPrefix(err.Error(), `invalid --branch-pattern "dependabot/["`) {
+			t.Errorf("expected the pattern to be rejected, got %v", err)
+		}
+	})
+}
+
+func TestTestCommand_ScenarioDir(t *testing.T) {
+	t.Cleanup(func() {
+		executeTestJob = infra.Run
+	})
+
+	dir := t.TempDir()
+	scenario, err := os.ReadFile("../../../../testdata/scenario.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"pass.yml", "nested/fail.yaml"} {
+		path := filepath.Join(dir, name)
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(path, scenario, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	executeTestJob = func(params infra.RunParams) error {
+		if strings.HasSuffix(params.InputName, "fail.yaml") {
+			return errors.New("update failed expectations")
+		}
+		return nil
+	}
+
+	var out bytes.Buffer
+	cmd := NewTestCommand()
+	cmd.SetOut(&out)
+	if err = cmd.ParseFlags([]string{"--scenario-dir", dir}); err != nil {
+		t.Fatal(err)
+	}
+	err = cmd.RunE(cmd, nil)
+	if err == nil || err.Error() != "1 of 2 scenarios failed" {
+		t.Errorf("expected one failure, got %v", err)
+	}
+	for _, want := range []string{
+		"FAIL    " + filepath.Join(dir, "nested/fail.yaml"),
+		"PASS    " + filepath.Join(dir, "pass.yml"),
+		"update failed expectations",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestTestCommand_Parallel(t *testing.T) {
+	t.Cleanup(func() {
+		executeTestJob = infra.Run
+	})
+
+	dir := t.TempDir()
+	scenario, err := os.ReadFile("../../../../testdata/scenario.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"a-slow-fail.yml", "b-pass.yml", "c-fail.yml"}
+	for _, name := range names {
+		if err = os.WriteFile(filepath.Join(dir, name), scenario, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var running, maxRunning atomic.Int32
+	executeTestJob = func(params infra.RunParams) error {
+		if n := running.Add(1); n > maxRunning.Load() {
+			maxRunning.Store(n)
+		}
+		defer running.Add(-1)
+		// the lines are written in pieces, as the container logs are, so they'd be mixed up if they weren't buffered
+		name := filepath.Base(params.InputName)
+		for i := 0; i < 3; i++ {
+			_, _ = fmt.Fprint(params.LogWriter, name)
+			time.Sleep(time.Millisecond)
+			_, _ = fmt.Fprintf(params.LogWriter, " line %d\n", i)
+		}
+		if strings.Contains(params.InputName, "slow") {
+			// finishes last, but should still be reported first
+			time.Sleep(50 * time.Millisecond)
+		}
+		if strings.Contains(params.InputName, "fail") {
+			return errors.New("failed " + filepath.Base(params.InputName))
+		}
+		return nil
+	}
+
+	var out, errOut bytes.Buffer
+	cmd := NewTestCommand()
+	cmd.SetOut(&out)
+	cmd.SetErr(&errOut)
+	if err = cmd.ParseFlags([]string{"--scenario-dir", dir, "--parallel", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	err = cmd.RunE(cmd, nil)
+	if err == nil || err.Error() != "2 of 3 scenarios failed" {
+		t.Errorf("expected two failures, got %v", err)
+	}
+	if maxRunning.Load() < 2 {
+		t.Errorf("expected scenarios to run at the same time")
+	}
+
+	want := fmt.Sprintf(`RESULT  SCENARIO
+FAIL    %[1]s
+PASS    %[2]s
+FAIL    %[3]s
+
+--- FAIL: %[1]s
+%[1]s: failed a-slow-fail.yml
+
+--- FAIL: %[3]s
+%[3]s: failed c-fail.yml
+`, filepath.Join(dir, names[0]), filepath.Join(dir, names[1]), filepath.Join(dir, names[2]))
+	if out.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, out.String())
+	}
+	for _, name := range names {
+		block := fmt.Sprintf("%[1]s line 0\n%[1]s line 1\n%[1]s line 2\n", name)
+		if !strings.Contains(errOut.String(), block) {
+			t.Errorf("expected the lines of %s together, got:\n%s", name, errOut.String())
+		}
+	}
+}
+
+func TestTestCommand_FailFast(t *testing.T) {
+	t.Cleanup(func() {
+		executeTestJob = infra.Run
+	})
+
+	dir := t.TempDir()
+	scenario, err := os.ReadFile("../../../../testdata/scenario.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"a-fail.yml", "b-pass.yml", "c-fail.yml"}
+	for _, name := range names {
+		if err = os.WriteFile(filepath.Join(dir, name), scenario, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ran []string
+	executeTestJob = func(params infra.RunParams) error {
+		ran = append(ran, filepath.Base(params.InputName))
+		if strings.Contains(params.InputName, "fail") {
+			return errors.New("failed " + filepath.Base(params.InputName))
+		}
+		return nil
+	}
+
+	var out bytes.Buffer
+	cmd := NewTestCommand()
+	cmd.SetOut(&out)
+	if err = cmd.ParseFlags([]string{"--scenario-dir", dir, "--fail-fast"}); err != nil {
+		t.Fatal(err)
+	}
+	err = cmd.RunE(cmd, nil)
+	if err == nil || err.Error() != "1 of 3 scenarios failed, 2 were skipped by --fail-fast" {
+		t.Errorf("expected one failure, got %v", err)
+	}
+	if len(ran) != 1 {
+		t.Errorf("expected only the first scenario to run, ran %v", ran)
+	}
+
+	want := fmt.Sprintf(`RESULT  SCENARIO
+FAIL    %[1]s
+SKIP    %[2]s
+SKIP    %[3]s
+
+--- FAIL: %[1]s
+%[1]s: failed a-fail.yml
+`, filepath.Join(dir, names[0]), filepath.Join(dir, names[1]), filepath.Join(dir, names[2]))
+	if out.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, out.String())
+	}
+}
+
+func Test_readScenarioFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenario.yml")
+	content := "input:\n  job:\n    package-manager: go_modules\n    source:\n      repo: rsc/quote\n      commit: {{ .sha }}\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("substitutes vars", func(t *testing.T) {
+		scenario, _, err := readScenarioFile(path, map[string]string{"sha": "1234"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scenario.Input.Job.Source.Commit != "1234" {
+			t.Errorf("expected the commit to be substituted, got %q", scenario.Input.Job.Source.Commit)
+		}
+	})
+
+	t.Run("undefined vars are an error", func(t *testing.T) {
+		_, _, err := readScenarioFile(path, map[string]string{"other": "value"})
+		if err == nil || !strings.Contains(err.Error(), `map has no entry for key "sha"`) {
+			t.Errorf("expected an undefined var error, got %v", err)
+		}
+	})
+
+	t.Run("resolves anchors like lint", func(t *testing.T) {
+		anchorsPath := filepath.Join(t.TempDir(), "scenario.yml")
+		content := `x-job: &job
+  package-manager: go_modules
+  source:
+    repo: rsc/quote
+x-pr: &pr
+  base-commit-sha: "1234"
+  pr-title: Bump rsc.io/quote
+input:
+  job:
+    <<: *job
+output:
+  - type: create_pull_request
+    expect:
+      data:
+        <<: *pr
+        pr-title: Bump rsc.io/quote from 1.5.1 to 1.5.2
+`
+		if err := os.WriteFile(anchorsPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(anchorsPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if findings, err := model.LintScenario(data); err != nil || len(findings) != 0 {
+			t.Fatalf("expected lint to accept the anchors, got %v %v", findings, err)
+		}
+		scenario, _, err := readScenarioFile(anchorsPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scenario.Input.Job.PackageManager != "go_modules" || scenario.Input.Job.Source.Repo != "rsc/quote" {
+			t.Errorf("expected the job to be merged in, got %+v", scenario.Input.Job)
+		}
+		want := map[string]any{"base-commit-sha": "1234", "pr-title": "Bump rsc.io/quote from 1.5.1 to 1.5.2"}
+		if len(scenario.Output) != 1 || !reflect.DeepEqual(scenario.Output[0].Expect.Data, want) {
+			t.Errorf("expected the explicit title to win over the merged one, got %+v", scenario.Output)
+		}
+	})
+
+	t.Run("reads TOML by extension", func(t *testing.T) {
+		tomlPath := filepath.Join(t.TempDir(), "scenario.toml")
+		content := "[input.job]\npackage-manager = \"go_modules\"\n\n[input.job.source]\nrepo = \"rsc/quote\"\n\n" +
+			"[[output]]\ntype = \"mark_as_processed\"\n\n[output.expect.data]\nbase-commit-sha = \"1234\"\n"
+		if err := os.WriteFile(tomlPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		scenario, inputRaw, err := readScenarioFile(tomlPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scenario.Input.Job.PackageManager != "go_modules" || scenario.Input.Job.Source.Repo != "rsc/quote" {
+			t.Errorf("expected the job to be decoded, got %+v", scenario.Input.Job)
+		}
+		if len(scenario.Output) != 1 || scenario.Output[0].Type != "mark_as_processed" {
+			t.Errorf("expected the output to be decoded, got %+v", scenario.Output)
+		}
+		if !strings.Contains(string(inputRaw), "package-manager: go_modules") {
+			t.Errorf("expected the raw input as YAML for the diff, got:\n%s", inputRaw)
+		}
+	})
+}
+
+func TestTestCommand_Runs(t *testing.T) {
+	t.Cleanup(func() {
+		executeTestJob = infra.Run
+	})
+
+	path := filepath.Join(t.TempDir(), "scenario.yml")
+	content := `runs:
+  - input:
+      job:
+        package-manager: go_modules
+        source:
+          repo: dependabot/smoke-tests
+  - input:
+      job:
+        package-manager: npm_and_yarn
+        source:
+          repo: dependabot/smoke-tests
+    output:
+      - type: mark_as_processed
+        expect:
+          data:
+            base-commit-sha: "1234"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var runs []infra.RunParams
+	executeTestJob = func(params infra.RunParams) error {
+		runs = append(runs, params)
+		return nil
+	}
+	cmd := NewTestCommand()
+	if err := cmd.ParseFlags([]string{"-f", path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.RunE(cmd, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(runs) != 2 {
+		t.Fatalf("expected 2 runs, got %d", len(runs))
+	}
+	if runs[0].Job.PackageManager != "go_modules" || len(runs[0].Expected) != 0 {
+		t.Errorf("unexpected first run %+v", runs[0])
+	}
+	if runs[1].Job.PackageManager != "npm_and_yarn" || len(runs[1].Expected) != 1 {
+		t.Errorf("unexpected second run %+v", runs[1])
+	}
+}
+
+func TestTestCommand_UpdateSnapshots(t *testing.T) {
+	t.Cleanup(func() {
+		executeTestJob = infra.Run
+	})
+	t.Setenv("UPDATE_SNAPSHOTS", "1")
+
+	path := filepath.Join(t.TempDir(), "scenario.yml")
+	content := `runs:
+  - input:
+      job:
+        package-manager: go_modules
+        source:
+          repo: dependabot/smoke-tests
+    output:
+      - type: mark_as_processed
+        expect:
+          data:
+            base-commit-sha: "1234"
+  - input:
+      job:
+        package-manager: npm_and_yarn
+        source:
+          repo: dependabot/smoke-tests
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	executeTestJob = func(params infra.RunParams) error {
+		if params.Output == "" || params.Output == path {
+			t.Fatalf("expected the actual output to go to a separate file, got %q", params.Output)
+		}
+		actual := fmt.Sprintf(`input:
+    job:
+        package-manager: %s
+output:
+    - type: mark_as_processed
+      expect:
+        data:
+            base-commit-sha: "5678"
+`, params.Job.PackageManager)
+		if err := os.WriteFile(params.Output, []byte(actual), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if params.Job.PackageManager == "go_modules" {
+			return fmt.Errorf("%w: base-commit-sha", infra.ErrExpectationsFailed)
+		}
+		return nil
+	}
+	cmd := NewTestCommand()
+	if err := cmd.ParseFlags([]string{"-f", path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.RunE(cmd, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	scenario, _, err := readScenarioFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenario.Runs) != 2 {
+		t.Fatalf("expected the runs to be kept, got %+v", scenario)
+	}
+	for i, run := range scenario.Runs {
+		if len(run.Output) != 1 || run.Output[0].Expect.Data.(map[string]any)["base-commit-sha"] != "5678" {
+			t.Errorf("expected run %d to have the actual output, got %+v", i+1, run.Output)
+		}
+	}
+
+	t.Run("keeps a TOML scenario as TOML", func(t *testing.T) {
+		tomlPath := filepath.Join(t.TempDir(), "scenario.toml")
+		tomlContent := `[input.job]
+package-manager = "go_modules"
+
+[input.job.source]
+repo = "dependabot/smoke-tests"
+
+[[output]]
+type = "mark_as_processed"
+
+[output.expect.data]
+base-commit-sha = "1234"
+`
+		if err := os.WriteFile(tomlPath, []byte(tomlContent), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := NewTestCommand()
+		if err := cmd.ParseFlags([]string{"-f", tomlPath}); err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.RunE(cmd, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(tomlPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), `base-commit-sha = "5678"`) {
+			t.Errorf("expected the snapshot to be written as TOML, got:\n%s", data)
+		}
+		scenario, _, err := readScenarioFile(tomlPath, nil)
+		if err != nil {
+			t.Fatalf("expected the snapshot to be readable: %v", err)
+		}
+		if len(scenario.Output) != 1 || scenario.Output[0].Expect.Data.(map[string]any)["base-commit-sha"] != "5678" {
+			t.Errorf("expected the actual output, got %+v", scenario.Output)
+		}
+	})
+
+	t.Run("can't be used with --output", func(t *testing.T) {
+		cmd := NewTestCommand()
+		if err := cmd.ParseFlags([]string{"-f", path, "-o", path}); err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.RunE(cmd, nil); err == nil {
+			t.Error("expected an error")
+		}
 	})
 }
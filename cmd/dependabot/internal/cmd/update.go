@@ -36,6 +36,9 @@ type UpdateFlags struct {
 	dependencies    []string
 	inputServerPort int
 	apiUrl          string
+	watch           bool
+	diff            bool
+	outputFormat    string
 }
 
 // A map of package manager names to credential type
@@ -58,6 +61,9 @@ func NewUpdateCommand() *cobra.Command {
 		    $ dependabot update -f input.yml
 	    `),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if flags.outputFormat != "yaml" && flags.outputFormat != "json" {
+				return fmt.Errorf("--output-format must be yaml or json, got %q", flags.outputFormat)
+			}
 			var outFile *os.File
 			if flags.output != "" {
 				var err error
@@ -76,7 +82,16 @@ func NewUpdateCommand() *cobra.Command {
 			processInput(input, &flags)
 
 			var writer io.Writer
-			if !flags.debugging {
+			var apiOptions []server.APIOption
+			var diff *dependencyDiff
+			if flags.diff {
+				// only the summary is printed, no pull requests are created by the fake API anyway
+				diff = &dependencyDiff{}
+				apiOptions = append(apiOptions, server.WithCallObserver(diff.observe))
+			} else if flags.watch {
+				// the watcher replaces the JSON output so they aren't interleaved
+				apiOptions = append(apiOptions, server.WithCallObserver(newCallWatcher(os.Stdout, useColor(os.Stdout)).observe))
+			} else if !flags.debugging {
 				writer = os.Stdout
 			}
 
@@ -93,6 +108,7 @@ func NewUpdateCommand() *cobra.Command {
 				Job:                 &input.Job,
 				LocalDir:            flags.local,
 				Output:              flags.output,
+				OutputFormat:        flags.outputFormat,
 				ProxyCertPath:       flags.proxyCertPath,
 				ProxyImage:          proxyImage,
 				PullImages:          flags.pullImages,
@@ -101,13 +117,20 @@ func NewUpdateCommand() *cobra.Command {
 				Volumes:             flags.volumes,
 				Writer:              writer,
 				ApiUrl:              flags.apiUrl,
+				APIOptions:          apiOptions,
 			}); err != nil {
 				if errors.Is(err, context.DeadlineExceeded) {
-					log.Fatalf("update timed out after %s", flags.timeout)
+					// the error includes what the updater didn't get to, the output is already written
+					log.Printf("update timed out after %s: %v", flags.timeout, err)
+					os.Exit(exitCodeTimeout)
 				}
 				log.Fatalf("updater failure: %v", err)
 			}
 
+			if diff != nil {
+				diff.print(cmd.OutOrStdout())
+			}
+
 			return nil
 		},
 	}
@@ -121,19 +144,26 @@ func NewUpdateCommand() *cobra.Command {
 	cmd.Flags().StringArrayVarP(&flags.dependencies, "dep", "", nil, "dependencies to update")
 
 	cmd.Flags().StringVarP(&flags.output, "output", "o", "", "write scenario to file")
+	cmd.Flags().StringVar(&flags.outputFormat, "output-format", "yaml", "format of the scenario written by --output, yaml or json")
 	cmd.Flags().StringVar(&flags.cache, "cache", "", "cache import/export directory")
 	cmd.Flags().StringVar(&flags.local, "local", "", "local directory to use as fetched source")
 	cmd.Flags().StringVar(&flags.proxyCertPath, "proxy-cert", "", "path to a certificate the proxy will trust")
 	cmd.Flags().StringVar(&flags.collectorConfigPath, "collector-config", "", "path to an OpenTelemetry collector config file")
 	cmd.Flags().BoolVar(&flags.pullImages, "pull", true, "pull the image if it isn't present")
 	cmd.Flags().BoolVar(&flags.debugging, "debug", false, "run an interactive shell inside the updater")
+	cmd.Flags().BoolVar(&flags.diff, "diff", false, "print a table of the dependency versions that would be updated instead of the API calls")
+	cmd.Flags().BoolVar(&flags.watch, "watch", false, "display each API call as it's received")
 	cmd.Flags().BoolVar(&flags.flamegraph, "flamegraph", false, "generate a flamegraph and other metrics")
 	cmd.Flags().StringArrayVarP(&flags.volumes, "volume", "v", nil, "mount volumes in Docker")
 	cmd.Flags().StringArrayVar(&flags.extraHosts, "extra-hosts", nil, "Docker extra hosts setting on the proxy")
-	cmd.Flags().DurationVarP(&flags.timeout, "timeout", "t", 0, "max time to run an update")
+	cmd.Flags().DurationVarP(&flags.timeout, "timeout", "t", 0, "max time to run an update, exiting with code 2 if it takes longer")
 	cmd.Flags().IntVar(&flags.inputServerPort, "input-port", 0, "port to use for securely passing input to the updater")
 	cmd.Flags().StringVarP(&flags.apiUrl, "api-url", "a", "", "the api dependabot should connect to.")
 
+	// the diff is made from the calls to the fake API, so it can't be used with the real one, and it replaces the watcher
+	cmd.MarkFlagsMutuallyExclusive("diff", "api-url")
+	cmd.MarkFlagsMutuallyExclusive("diff", "watch")
+
 	return cmd
 }
 
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"io"
 	"net/http"
 	"os"
 	"reflect"
@@ -127,6 +128,22 @@ func Test_processInput(t *testing.T) {
 	})
 }
 
+func TestUpdateCommand_diffFlagConflicts(t *testing.T) {
+	for _, args := range [][]string{
+		{"go_modules", "rsc/quote", "--diff", "--api-url", "https://api.example.com"},
+		{"go_modules", "rsc/quote", "--diff", "--watch"},
+	} {
+		cmd := NewUpdateCommand()
+		cmd.SetArgs(args)
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		err := cmd.Execute()
+		if err == nil || !strings.Contains(err.Error(), "were all set") {
+			t.Errorf("%v: expected a flag conflict error, got %v", args, err)
+		}
+	}
+}
+
 func Test_extractInput(t *testing.T) {
 	t.Run("test arguments", func(t *testing.T) {
 		cmd := NewUpdateCommand()
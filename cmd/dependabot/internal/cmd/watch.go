@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dependabot/cli/internal/server"
+	"github.com/docker/cli/cli/streams"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
+)
+
+// watchColors are the colors used to display each kind of call, anything else is uncolored
+var watchColors = map[string]string{
+	"create_pull_request":             colorGreen,
+	"update_pull_request":             colorGreen,
+	"close_pull_request":              colorYellow,
+	"record_update_job_warning":       colorYellow,
+	"record_update_job_error":         colorRed,
+	"record_update_job_unknown_error": colorRed,
+	"mark_as_processed":               colorCyan,
+}
+
+// callWatcher prints each call the API receives as it happens
+type callWatcher struct {
+	out   io.Writer
+	color bool
+	start time.Time
+}
+
+func newCallWatcher(out io.Writer, color bool) *callWatcher {
+	return &callWatcher{out: out, color: color, start: time.Now()}
+}
+
+// useColor reports whether out is a terminal and NO_COLOR isn't set, so piped output and logs stay plain
+func useColor(out io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	return streams.NewOut(out).IsTerminal()
+}
+
+func (w *callWatcher) observe(call server.APICall) {
+	data, err := yaml.Marshal(call.Data)
+	if err != nil {
+		data = []byte(fmt.Sprintf("failed to marshal: %v\n", err))
+	}
+	elapsed := call.ReceivedAt.Sub(w.start).Truncate(time.Millisecond)
+
+	color, reset := watchColors[call.Kind], colorReset
+	if color == "" || !w.color {
+		color, reset = "", ""
+	}
+	_, _ = fmt.Fprintf(w.out, "%s#%d +%s %s%s\n", color, call.Seq, elapsed, call.Kind, reset)
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		_, _ = fmt.Fprintf(w.out, "    %s\n", line)
+	}
+}
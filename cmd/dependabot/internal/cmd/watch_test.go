@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dependabot/cli/internal/model"
+	"github.com/dependabot/cli/internal/server"
+)
+
+func Test_callWatcher(t *testing.T) {
+	var out bytes.Buffer
+	w := newCallWatcher(&out, true)
+	w.observe(server.APICall{
+		Seq:        2,
+		Kind:       "create_pull_request",
+		ReceivedAt: w.start.Add(1500 * time.Millisecond),
+		Data:       model.CreatePullRequest{BaseCommitSha: "1234"},
+	})
+	w.observe(server.APICall{
+		Seq:        3,
+		Kind:       "increment_metric",
+		ReceivedAt: w.start.Add(2 * time.Second),
+		Data:       model.IncrementMetric{Metric: "updater.started"},
+	})
+
+	want := "\033[32m#2 +1.5s create_pull_request\033[0m\n" +
+		"    base-commit-sha: \"1234\"\n" +
+		"    dependencies: []\n" +
+		"    updated-dependency-files: []\n" +
+		"#3 +2s increment_metric\n" +
+		"    metric: updater.started\n" +
+		"    tags: {}\n"
+	if out.String() != want {
+		t.Errorf("expected:\n%q\ngot:\n%q", want, out.String())
+	}
+}
+
+func Test_callWatcher_plain(t *testing.T) {
+	var out bytes.Buffer
+	w := newCallWatcher(&out, false)
+	w.observe(server.APICall{
+		Seq:        1,
+		Kind:       "mark_as_processed",
+		ReceivedAt: w.start.Add(time.Second),
+		Data:       model.MarkAsProcessed{BaseCommitSha: "1234"},
+	})
+
+	want := "#1 +1s mark_as_processed\n" +
+		"    base-commit-sha: \"1234\"\n"
+	if out.String() != want {
+		t.Errorf("expected:\n%q\ngot:\n%q", want, out.String())
+	}
+}
+
+func Test_useColor(t *testing.T) {
+	// a buffer stands in for stdout piped to a file or a CI log
+	if useColor(&bytes.Buffer{}) {
+		t.Error("expected no color when the output isn't a terminal")
+	}
+	t.Setenv("NO_COLOR", "")
+	if useColor(os.Stdout) {
+		t.Error("expected no color when NO_COLOR is set")
+	}
+}
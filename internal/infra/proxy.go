@@ -29,6 +29,7 @@ type Proxy struct {
 	containerID string
 	url         string
 	ca          CertificateAuthority
+	logs        io.Writer
 }
 
 func NewProxy(ctx context.Context, cli *client.Client, params *RunParams, nets *Networks) (*Proxy, error) {
@@ -98,6 +99,7 @@ func NewProxy(ctx context.Context, cli *client.Client, params *RunParams, nets *
 		cli:         cli,
 		containerID: proxyContainer.ID,
 		ca:          ca,
+		logs:        params.logWriter(),
 	}
 
 	if err = putProxyConfig(ctx, cli, proxyConfig, proxyContainer.ID); err != nil {
@@ -163,7 +165,7 @@ func (p *Proxy) TailLogs(ctx context.Context, cli *client.Client) {
 
 	r, w := io.Pipe()
 	go func() {
-		_, _ = io.Copy(os.Stderr, prefixer.New(r, "  proxy | "))
+		_, _ = io.Copy(p.logs, prefixer.New(r, "  proxy | "))
 	}()
 	_, _ = stdcopy.StdCopy(w, w, out)
 }
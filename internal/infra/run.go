@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -43,6 +44,8 @@ type RunParams struct {
 	CacheDir string
 	// write output to a file
 	Output string
+	// OutputFormat is the format of the Output file, yaml (the default) or json
+	OutputFormat string
 	// ProxyCertPath is the path to a cert for the proxy to trust
 	ProxyCertPath string
 	// attempt to pull images if they aren't local?
@@ -67,14 +70,25 @@ type RunParams struct {
 	// CollectorConfigPath is the path to the OpenTelemetry collector configuration file
 	CollectorConfigPath string
 	// Writer is where API calls will be written to
-	Writer    io.Writer
+	Writer io.Writer
+	// LogWriter is where the proxy and updater logs and the diff of the expectations are written, os.Stderr if nil
+	LogWriter io.Writer
 	InputName string
 	InputRaw  []byte
 	ApiUrl    string
+	// APIOptions configure the fake API the updater talks to
+	APIOptions []server.APIOption
 }
 
 var gitShaRegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
 
+func (p *RunParams) logWriter() io.Writer {
+	if p.LogWriter != nil {
+		return p.LogWriter
+	}
+	return os.Stderr
+}
+
 func (p *RunParams) Validate() error {
 	if p.Job == nil {
 		return fmt.Errorf("job is required")
@@ -104,7 +118,7 @@ func Run(params RunParams) error {
 		cancel()
 	}()
 
-	api := server.NewAPI(params.Expected, params.Writer)
+	api := server.NewAPI(params.Expected, params.Writer, params.APIOptions...)
 	defer api.Stop()
 
 	var outFile *os.File
@@ -132,6 +146,10 @@ func Run(params RunParams) error {
 		params.ApiUrl = fmt.Sprintf("http://host.docker.internal:%v", api.Port())
 	}
 	if err := runContainers(ctx, params); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			// the containers are already stopped, report and write what the updater got through before the timeout
+			return errors.Join(err, completeTimedOut(params, api, outFile))
+		}
 		return err
 	}
 
@@ -149,6 +167,16 @@ func Run(params RunParams) error {
 	return nil
 }
 
+// completeTimedOut finishes a run that timed out like one that ended, writing the output and returning
+// the errors it found, e.g. the expectations the updater didn't get to
+func completeTimedOut(params RunParams, api *server.API, outFile *os.File) error {
+	api.Complete()
+	if _, err := generateOutput(params, api, outFile); err != nil {
+		return err
+	}
+	return errors.Join(api.Errors...)
+}
+
 func generateOutput(params RunParams, api *server.API, outFile *os.File) ([]byte, error) {
 	if params.Job.Source.Commit == "" {
 		// store the SHA we worked with for reproducible tests
@@ -163,7 +191,7 @@ func generateOutput(params RunParams, api *server.API, outFile *os.File) ([]byte
 		}
 	}
 
-	output, err := yaml.Marshal(api.Actual)
+	output, err := marshalScenario(api.Actual, params.OutputFormat)
 	if err != nil {
 		return nil, fmt.Errorf("failed to write output: %v", err)
 	}
@@ -183,6 +211,20 @@ func generateOutput(params RunParams, api *server.API, outFile *os.File) ([]byte
 	return output, nil
 }
 
+// marshalScenario encodes the scenario as YAML, or as JSON with the same field names if format is json
+func marshalScenario(scenario model.Scenario, format string) ([]byte, error) {
+	data, err := yaml.Marshal(scenario)
+	if err != nil || format != "json" {
+		return data, err
+	}
+	// converting the YAML keeps the field names and omitted fields identical, which the JSON tags don't always
+	var generic any
+	if err = yaml.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(generic, "", "  ")
+}
+
 func diff(params RunParams, outFile *os.File, output []byte) error {
 	inName := "input.yml"
 	outName := "output.yml"
@@ -194,14 +236,16 @@ func diff(params RunParams, outFile *os.File, output []byte) error {
 	}
 	aString := string(params.InputRaw)
 	edits := myers.ComputeEdits(span.URIFromPath(inName), aString, string(output))
-	_, _ = fmt.Fprintln(os.Stderr, gotextdiff.ToUnified(inName, outName, aString, edits))
+	_, _ = fmt.Fprintln(params.logWriter(), gotextdiff.ToUnified(inName, outName, aString, edits))
 
-	return fmt.Errorf("update failed expectations")
+	return ErrExpectationsFailed
 }
 
 var (
 	defaultApiEndpoint = "https://api.github.com"
 	ErrWriteAccess     = fmt.Errorf("for security, credentials used in update are not allowed to have write access to GitHub API")
+	// ErrExpectationsFailed is returned by Run when the updater's calls didn't match the expected output
+	ErrExpectationsFailed = fmt.Errorf("update failed expectations")
 )
 
 // checkCredAccess returns an error if any of the tokens in the job definition have write access.
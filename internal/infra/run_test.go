@@ -2,18 +2,22 @@ package infra
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/dependabot/cli/internal/server"
 
 	"github.com/dependabot/cli/internal/model"
+	"gopkg.in/yaml.v3"
 )
 
 func Test_checkCredAccess(t *testing.T) {
@@ -159,3 +163,83 @@ func Test_generateIgnoreConditions(t *testing.T) {
 		}
 	})
 }
+
+func Test_completeTimedOut(t *testing.T) {
+	api := server.NewAPI([]model.Output{
+		{Type: "update_dependency_list", Expect: model.UpdateWrapper{Data: model.UpdateDependencyList{
+			Dependencies: []model.Dependency{}, DependencyFiles: []string{"/go.mod"},
+		}}},
+		{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}}},
+	}, nil)
+	defer api.Stop()
+	if err := api.InjectRequest("update_dependency_list", []byte(`{"data":{"dependencies":[],"dependency_files":["/go.mod"]}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	outFile, err := os.Create(filepath.Join(t.TempDir(), "output.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outFile.Close()
+	params := RunParams{Job: &model.Job{PackageManager: "go_modules"}, Output: outFile.Name()}
+
+	err = completeTimedOut(params, api, outFile)
+	if err == nil || !strings.Contains(err.Error(), "expectation not met: mark_as_processed") {
+		t.Errorf("expected the unmet expectation to be reported, got %v", err)
+	}
+	data, err := os.ReadFile(outFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "type: update_dependency_list") {
+		t.Errorf("expected the calls before the timeout to be written, got:\n%s", data)
+	}
+}
+
+func Test_marshalScenario(t *testing.T) {
+	version := "1.5.2"
+	scenario := model.Scenario{
+		Input: model.Input{
+			Job: model.Job{
+				PackageManager: "go_modules",
+				Source:         model.Source{Repo: "rsc/quote", Directory: "/"},
+			},
+			Credentials: []model.Credential{{"type": "git_source", "host": "github.com"}},
+		},
+		Output: []model.Output{
+			{
+				Type: "create_pull_request",
+				Expect: model.UpdateWrapper{Data: model.CreatePullRequest{
+					BaseCommitSha: "1234",
+					Dependencies:  []model.Dependency{{Name: "rsc.io/quote", Version: &version, Requirements: []model.Requirement{}}},
+					PRTitle:       "Bump rsc.io/quote",
+				}},
+				PartialMatch: true,
+			},
+			{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}}},
+		},
+	}
+
+	yamlData, err := marshalScenario(scenario, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonData, err := marshalScenario(scenario, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(jsonData) {
+		t.Fatalf("expected JSON, got %s", jsonData)
+	}
+
+	var fromYAML, fromJSON model.Scenario
+	if err = yaml.Unmarshal(yamlData, &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(jsonData, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		t.Errorf("expected the formats to decode the same\nyaml: %+v\njson: %+v", fromYAML, fromJSON)
+	}
+}
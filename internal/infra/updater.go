@@ -38,6 +38,7 @@ const (
 type Updater struct {
 	cli         *client.Client
 	containerID string
+	logs        io.Writer
 
 	// ExitCode is set once an Updater command has completed.
 	ExitCode *int
@@ -99,6 +100,7 @@ func NewUpdater(ctx context.Context, cli *client.Client, net *Networks, params *
 	updater := &Updater{
 		cli:         cli,
 		containerID: updaterContainer.ID,
+		logs:        params.logWriter(),
 	}
 
 	if err = putUpdaterInputs(ctx, cli, prox.ca.Cert, updaterContainer.ID, params.Job); err != nil {
@@ -251,7 +253,7 @@ func (u *Updater) RunCmd(ctx context.Context, cmd, user string, env ...string) e
 
 	r, w := io.Pipe()
 	go func() {
-		_, _ = io.Copy(os.Stderr, prefixer.New(r, "updater | "))
+		_, _ = io.Copy(u.logs, prefixer.New(r, "updater | "))
 	}()
 
 	ch := make(chan struct{})
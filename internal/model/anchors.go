@@ -0,0 +1,95 @@
+package model
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// ResolveAnchors is a transform for UnmarshalWithIncludes that resolves anchors the way LintScenario does,
+// so a scenario is run with the same fields it was linted with
+func ResolveAnchors(doc *yaml.Node) error {
+	resolveAnchors(doc)
+	return nil
+}
+
+// resolveAnchors replaces each alias in the document with a copy of the node it refers to and expands
+// merge keys (<<), so strict decoding sees the same fields a normal decode would. Top level keys that
+// only exist to define an anchor, such as `x-job: &job`, are removed since they aren't part of the scenario.
+func resolveAnchors(doc *yaml.Node) {
+	if len(doc.Content) == 0 {
+		return
+	}
+	root := doc.Content[0]
+	if root.Kind == yaml.MappingNode {
+		var content []*yaml.Node
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			key, value := root.Content[i], root.Content[i+1]
+			if value.Anchor != "" && !isScenarioKey(key.Value) {
+				continue
+			}
+			content = append(content, key, value)
+		}
+		root.Content = content
+	}
+	resolveAliases(root)
+}
+
+func isScenarioKey(key string) bool {
+	switch key {
+	case "input", "output", "runs":
+		return true
+	}
+	return false
+}
+
+func resolveAliases(node *yaml.Node) {
+	if node.Kind == yaml.AliasNode {
+		*node = *copyNode(node.Alias)
+	}
+	node.Anchor = ""
+	for _, child := range node.Content {
+		resolveAliases(child)
+	}
+	if node.Kind == yaml.MappingNode {
+		expandMergeKeys(node)
+	}
+}
+
+// expandMergeKeys replaces `<<: *anchor` with the keys of the anchored mapping that aren't already set
+func expandMergeKeys(node *yaml.Node) {
+	var explicit, merged []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Kind != yaml.ScalarNode || key.Value != "<<" || key.Style != 0 {
+			explicit = append(explicit, key, value)
+			continue
+		}
+		sources := []*yaml.Node{value}
+		if value.Kind == yaml.SequenceNode {
+			sources = value.Content
+		}
+		for _, source := range sources {
+			if source.Kind == yaml.MappingNode {
+				merged = append(merged, source.Content...)
+			}
+		}
+	}
+	if merged == nil {
+		return
+	}
+	content := explicit
+	for i := 0; i+1 < len(merged); i += 2 {
+		if mappingIndex(&yaml.Node{Kind: yaml.MappingNode, Content: content}, merged[i].Value) < 0 {
+			content = append(content, merged[i], merged[i+1])
+		}
+	}
+	node.Content = content
+}
+
+func copyNode(node *yaml.Node) *yaml.Node {
+	copied := *node
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		copied.Content[i] = copyNode(child)
+	}
+	return &copied
+}
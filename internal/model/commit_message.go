@@ -0,0 +1,50 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultCommitMessageLineLength is the longest subject line ValidateCommitMessage allows unless configured
+const DefaultCommitMessageLineLength = 72
+
+// CommitMessageConfig is what ValidateCommitMessage requires of a commit message
+type CommitMessageConfig struct {
+	// Prefix the subject line must start with, e.g. "chore(deps)"
+	Prefix string
+	// Suffix the subject line must end with
+	Suffix string
+	// MaxLineLength is the longest the subject line can be, DefaultCommitMessageLineLength if it's 0.
+	// Only the subject is checked since the body has links to release notes that can't be wrapped.
+	MaxLineLength int
+	// ConventionalCommits requires the subject to be "type(scope): description", the scope is optional
+	ConventionalCommits bool
+}
+
+var conventionalCommit = regexp.MustCompile(`^[a-z]+(\([^()]+\))?!?: \S`)
+
+// ValidateCommitMessage checks the message meets the requirements of cfg, e.g. those of a Dependabot configuration
+func ValidateCommitMessage(msg string, cfg CommitMessageConfig) error {
+	subject, _, _ := strings.Cut(msg, "\n")
+	if subject == "" {
+		return fmt.Errorf("commit message has no subject line")
+	}
+	if !strings.HasPrefix(subject, cfg.Prefix) {
+		return fmt.Errorf("subject line %q doesn't start with %q", subject, cfg.Prefix)
+	}
+	if !strings.HasSuffix(subject, cfg.Suffix) {
+		return fmt.Errorf("subject line %q doesn't end with %q", subject, cfg.Suffix)
+	}
+	maxLength := cfg.MaxLineLength
+	if maxLength == 0 {
+		maxLength = DefaultCommitMessageLineLength
+	}
+	if length := len([]rune(subject)); length > maxLength {
+		return fmt.Errorf("subject line %q is %d characters, the limit is %d", subject, length, maxLength)
+	}
+	if cfg.ConventionalCommits && !conventionalCommit.MatchString(subject) {
+		return fmt.Errorf("subject line %q isn't a conventional commit, e.g. \"chore(deps): bump lodash\"", subject)
+	}
+	return nil
+}
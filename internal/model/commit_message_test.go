@@ -0,0 +1,37 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateCommitMessage(t *testing.T) {
+	body := "\n\nBumps [lodash](https://github.com/lodash/lodash) from 4.17.20 to 4.17.21, a line longer than the subject limit."
+	tests := []struct {
+		name string
+		msg  string
+		cfg  CommitMessageConfig
+		err  string
+	}{
+		{name: "defaults", msg: "Bump lodash from 4.17.20 to 4.17.21" + body},
+		{name: "prefix", msg: "chore(deps): bump lodash", cfg: CommitMessageConfig{Prefix: "chore(deps)"}},
+		{name: "missing prefix", msg: "Bump lodash", cfg: CommitMessageConfig{Prefix: "chore(deps)"}, err: `doesn't start with "chore(deps)"`},
+		{name: "missing suffix", msg: "Bump lodash", cfg: CommitMessageConfig{Suffix: "[skip ci]"}, err: `doesn't end with "[skip ci]"`},
+		{name: "too long", msg: strings.Repeat("a", 73), err: "is 73 characters, the limit is 72"},
+		{name: "custom length", msg: "Bump lodash", cfg: CommitMessageConfig{MaxLineLength: 10}, err: "is 11 characters, the limit is 10"},
+		{name: "conventional", msg: "build(deps)!: bump lodash", cfg: CommitMessageConfig{ConventionalCommits: true}},
+		{name: "not conventional", msg: "Bump lodash", cfg: CommitMessageConfig{ConventionalCommits: true}, err: "isn't a conventional commit"},
+		{name: "empty", msg: "", err: "no subject line"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCommitMessage(tt.msg, tt.cfg)
+			if tt.err == "" && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
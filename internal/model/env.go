@@ -0,0 +1,74 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envVarPattern matches ${NAME} and ${NAME!}, the ! marks the variable as required
+var envVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(!?)\}`)
+
+// ExpandEnv returns a transform for UnmarshalWithIncludes that replaces ${NAME} in scalar values with the
+// variable's value from lookup, e.g. os.LookupEnv. Unset variables are replaced with an empty string and
+// reported to warn, unless they're written ${NAME!} in which case they're an error.
+// Values aren't expanded again, so a variable can't refer to another.
+func ExpandEnv(lookup func(string) (string, bool), warn func(string)) func(*yaml.Node) error {
+	return func(node *yaml.Node) error {
+		return expandEnv(node, lookup, warn)
+	}
+}
+
+func expandEnv(node *yaml.Node, lookup func(string) (string, bool), warn func(string)) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return expandScalar(node, lookup, warn)
+	case yaml.MappingNode:
+		// only the values, keys are left alone
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := expandEnv(node.Content[i], lookup, warn); err != nil {
+				return err
+			}
+		}
+	default:
+		for _, child := range node.Content {
+			if err := expandEnv(child, lookup, warn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func expandScalar(node *yaml.Node, lookup func(string) (string, bool), warn func(string)) error {
+	if !strings.Contains(node.Value, "${") {
+		return nil
+	}
+	var missing []string
+	expanded := envVarPattern.ReplaceAllStringFunc(node.Value, func(match string) string {
+		groups := envVarPattern.FindStringSubmatch(match)
+		name, required := groups[1], groups[2] == "!"
+		value, ok := lookup(name)
+		if !ok {
+			if required {
+				missing = append(missing, name)
+			} else if warn != nil {
+				warn(fmt.Sprintf("line %d: environment variable %s is not set, using an empty string", node.Line, name))
+			}
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return fmt.Errorf("line %d: required environment variable %s is not set", node.Line, strings.Join(missing, ", "))
+	}
+	if expanded != node.Value {
+		node.Value = expanded
+		if node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			// resolve the type again so ${PORT} can be used for a number
+			node.Tag = ""
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	env := map[string]string{
+		"REPO":    "rsc/quote",
+		"SHA":     "1234",
+		"RUNTIME": "60",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	t.Run("substitutes nested values", func(t *testing.T) {
+		data := `runs:
+  - input:
+      job:
+        package-manager: go_modules
+        source:
+          repo: ${REPO}
+          commit: "${SHA}"
+        max-updater-run-time: ${RUNTIME}
+    output:
+      - type: mark_as_processed
+        expect:
+          data:
+            base-commit-sha: prefix-${SHA}-${SHA}
+`
+		var scenario Scenario
+		if err := UnmarshalWithIncludes([]byte(data), "scenario.yml", &scenario, ExpandEnv(lookup, nil)); err != nil {
+			t.Fatal(err)
+		}
+		job := scenario.Runs[0].Input.Job
+		if job.Source.Repo != "rsc/quote" || job.Source.Commit != "1234" || job.MaxUpdaterRunTime != 60 {
+			t.Errorf("expected the variables to be substituted, got %+v", job)
+		}
+		expect := scenario.Runs[0].Output[0].Expect.Data.(map[string]any)
+		if expect["base-commit-sha"] != "prefix-1234-1234" {
+			t.Errorf("expected every variable in a value to be substituted, got %v", expect["base-commit-sha"])
+		}
+	})
+
+	t.Run("missing variables are empty with a warning", func(t *testing.T) {
+		var warnings []string
+		var scenario Scenario
+		data := "input:\n  job:\n    package-manager: go_modules\n    source:\n      repo: ${MISSING}\n"
+		err := UnmarshalWithIncludes([]byte(data), "scenario.yml", &scenario, ExpandEnv(lookup, func(msg string) {
+			warnings = append(warnings, msg)
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scenario.Input.Job.Source.Repo != "" {
+			t.Errorf("expected an empty value, got %q", scenario.Input.Job.Source.Repo)
+		}
+		if len(warnings) != 1 || !strings.Contains(warnings[0], "MISSING is not set") {
+			t.Errorf("expected a warning, got %v", warnings)
+		}
+	})
+
+	t.Run("missing required variables are an error", func(t *testing.T) {
+		var scenario Scenario
+		data := "input:\n  job:\n    package-manager: go_modules\n    source:\n      repo: ${MISSING!}\n"
+		err := UnmarshalWithIncludes([]byte(data), "scenario.yml", &scenario, ExpandEnv(lookup, nil))
+		if err == nil || err.Error() != "line 5: required environment variable MISSING is not set" {
+			t.Errorf("expected a required variable error, got %v", err)
+		}
+	})
+
+	t.Run("values aren't expanded again", func(t *testing.T) {
+		env["OUTER"] = "${SHA}"
+		defer delete(env, "OUTER")
+		var scenario Scenario
+		data := "input:\n  job:\n    package-manager: ${OUTER}\n"
+		if err := UnmarshalWithIncludes([]byte(data), "scenario.yml", &scenario, ExpandEnv(lookup, nil)); err != nil {
+			t.Fatal(err)
+		}
+		if scenario.Input.Job.PackageManager != "${SHA}" {
+			t.Errorf("expected the value to be used as is, got %q", scenario.Input.Job.PackageManager)
+		}
+	})
+}
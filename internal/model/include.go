@@ -0,0 +1,79 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnmarshalWithIncludes is like yaml.Unmarshal but replaces any `!include other.yaml` value with the contents
+// of that file. Include paths are relative to the file that contains them, which is named by file.
+// Each transform, e.g. ExpandEnv, is applied to the document in order once the includes are resolved.
+func UnmarshalWithIncludes(data []byte, file string, out any, transforms ...func(*yaml.Node) error) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if err := resolveIncludes(&doc, file, []string{absPath(file)}); err != nil {
+		return err
+	}
+	for _, transform := range transforms {
+		if err := transform(&doc); err != nil {
+			return err
+		}
+	}
+	if doc.Kind == 0 {
+		// empty document
+		return nil
+	}
+	return doc.Decode(out)
+}
+
+func resolveIncludes(node *yaml.Node, file string, stack []string) error {
+	if node.Tag == "!include" {
+		if node.Kind != yaml.ScalarNode {
+			return fmt.Errorf("%s:%d: !include requires a file path", file, node.Line)
+		}
+		path := node.Value
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(file), path)
+		}
+		for _, included := range stack {
+			if included == absPath(path) {
+				return fmt.Errorf("circular include: %s -> %s", strings.Join(stack, " -> "), path)
+			}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%s:%d: failed to include: %w", file, node.Line, err)
+		}
+		var doc yaml.Node
+		if err = yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("failed to decode included file %s: %w", path, err)
+		}
+		if err = resolveIncludes(&doc, path, append(stack, absPath(path))); err != nil {
+			return err
+		}
+		if len(doc.Content) == 0 {
+			return fmt.Errorf("included file %s is empty", path)
+		}
+		*node = *doc.Content[0]
+		return nil
+	}
+	for _, child := range node.Content {
+		if err := resolveIncludes(child, file, stack); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalWithIncludes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("inlines included files relative to the including file", func(t *testing.T) {
+		write("shared/job.yaml", "package-manager: go_modules\nsource: !include source.yaml\n")
+		write("shared/source.yaml", "provider: github\nrepo: rsc/quote\n")
+		path := write("scenario.yaml", "input:\n  job: !include shared/job.yaml\n")
+
+		data, _ := os.ReadFile(path)
+		var scenario Scenario
+		if err := UnmarshalWithIncludes(data, path, &scenario); err != nil {
+			t.Fatal(err)
+		}
+		if scenario.Input.Job.PackageManager != "go_modules" || scenario.Input.Job.Source.Repo != "rsc/quote" {
+			t.Errorf("expected the includes to be inlined, got %+v", scenario.Input.Job)
+		}
+	})
+
+	t.Run("reports circular includes", func(t *testing.T) {
+		write("a.yaml", "b: !include b.yaml\n")
+		write("b.yaml", "a: !include a.yaml\n")
+		path := write("cycle.yaml", "input: !include a.yaml\n")
+
+		data, _ := os.ReadFile(path)
+		var scenario Scenario
+		err := UnmarshalWithIncludes(data, path, &scenario)
+		if err == nil || !strings.Contains(err.Error(), "circular include") {
+			t.Errorf("expected a circular include error, got %v", err)
+		}
+	})
+}
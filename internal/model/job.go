@@ -107,6 +107,8 @@ type Dependency struct {
 	Requirements         []Requirement  `json:"requirements"`
 	Version              *string        `json:"version" yaml:"version"`
 	Removed              bool           `json:"removed,omitempty" yaml:"removed,omitempty"`
+	// Ecosystem is set by updaters that list dependencies from several ecosystems, e.g. npm and pip
+	Ecosystem string `json:"ecosystem,omitempty" yaml:"ecosystem,omitempty"`
 }
 
 type Requirement struct {
@@ -0,0 +1,222 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LintSeverity is how serious a LintFinding is
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "error"
+	LintWarning LintSeverity = "warning"
+)
+
+// LintFinding is a problem LintScenario found in a scenario file
+type LintFinding struct {
+	// Line is where the problem is in the file, or 0 if it applies to the whole file
+	Line     int
+	Severity LintSeverity
+	// Rule names the check that found the problem, e.g. unknown-field
+	Rule    string
+	Message string
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%d: %s: %s (%s)", f.Line, f.Severity, f.Message, f.Rule)
+}
+
+// LintScenario checks a scenario file for common mistakes that ValidateScenario allows,
+// such as misspelled fields and outputs in an order the updater never produces.
+// The findings are sorted by line. An error is only returned if the file isn't valid YAML.
+func LintScenario(data []byte) ([]LintFinding, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return []LintFinding{{Severity: LintError, Rule: "invalid", Message: "scenario must be a mapping"}}, nil
+	}
+	// anchors are resolved first so merge keys and anchor definitions aren't mistaken for unknown fields
+	resolveAnchors(&doc)
+
+	findings := lintFields(doc.Content[0], reflect.TypeOf(Scenario{}))
+	for _, run := range runNodes(doc.Content[0]) {
+		findings = append(findings, lintRun(run)...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Line < findings[j].Line
+	})
+	return findings, nil
+}
+
+// runNodes returns the nodes with an input and output, the scenario itself or each of its runs
+func runNodes(scenario *yaml.Node) []*yaml.Node {
+	runs := mappingValue(scenario, "runs")
+	if runs == nil || runs.Kind != yaml.SequenceNode {
+		return []*yaml.Node{scenario}
+	}
+	return runs.Content
+}
+
+func lintRun(node *yaml.Node) []LintFinding {
+	var findings []LintFinding
+	var run Run
+	if err := node.Decode(&run); err != nil {
+		// reported by lintFields
+		return nil
+	}
+	for _, err := range validateRun(Run{Input: run.Input}) {
+		findings = append(findings, LintFinding{Line: node.Line, Severity: LintError, Rule: "invalid", Message: err.Error()})
+	}
+
+	outputs := mappingValue(node, "output")
+	if outputs == nil || outputs.Kind != yaml.SequenceNode {
+		return findings
+	}
+	var dependencySets []string
+	for i, output := range run.Output {
+		line := outputs.Content[i].Line
+		if output.Type == "" && output.Expect.Data == nil {
+			continue
+		}
+		if payloadType, ok := lookupOutputType(output.Type); ok {
+			// checked here rather than by validateOutput so the line of the field is known
+			if data := mappingValue(mappingValue(outputs.Content[i], "expect"), "data"); data != nil {
+				unknown := lintFields(data, payloadType)
+				findings = append(findings, unknown...)
+				if len(unknown) > 0 {
+					continue
+				}
+			}
+		}
+		if errs := LintOutput(output); len(errs) > 0 {
+			for _, err := range errs {
+				findings = append(findings, LintFinding{Line: line, Severity: LintError, Rule: "invalid", Message: err.Error()})
+			}
+			continue
+		}
+
+		switch output.Type {
+		case "update_dependency_list":
+			if i > 0 {
+				findings = append(findings, LintFinding{Line: line, Severity: LintWarning, Rule: "output-order",
+					Message: "update_dependency_list is the first call the updater makes"})
+			}
+		case "mark_as_processed":
+			if i < len(run.Output)-1 {
+				findings = append(findings, LintFinding{Line: line, Severity: LintWarning, Rule: "output-order",
+					Message: "mark_as_processed is the last call the updater makes"})
+			}
+		case "record_update_job_error":
+			var jobError RecordUpdateJobError
+			if data, err := yaml.Marshal(output.Expect.Data); err == nil && yaml.Unmarshal(data, &jobError) == nil {
+				if err = ValidateErrorType(jobError.ErrorType); err != nil {
+					findings = append(findings, LintFinding{Line: line, Severity: LintWarning, Rule: "unknown-error-type", Message: err.Error()})
+				}
+			}
+		case "create_pull_request":
+			set := dependencySet(output)
+			if set == "" {
+				continue
+			}
+			if slices.Contains(dependencySets, set) {
+				findings = append(findings, LintFinding{Line: line, Severity: LintError, Rule: "duplicate-pull-request",
+					Message: fmt.Sprintf("create_pull_request for %s is expected more than once", set)})
+			}
+			dependencySets = append(dependencySets, set)
+		}
+	}
+	return findings
+}
+
+// LintOutput checks an output of a scenario is valid, and that the values it expects are ones the
+// updater could send, e.g. a branch-name Git allows. It doesn't need the API running.
+func LintOutput(o Output) []error {
+	if err := validateOutput(o); err != nil {
+		return []error{err}
+	}
+	var errs []error
+	if o.Type == "create_pull_request" && o.Expect.Data != nil {
+		var pr CreatePullRequest
+		// validateOutput already decoded it, so this can't fail
+		data, _ := yaml.Marshal(o.Expect.Data)
+		_ = yaml.Unmarshal(data, &pr)
+		if pr.BranchName != "" {
+			if err := ValidateBranchName(pr.BranchName); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid branch-name: %w", o.Type, err))
+			}
+		}
+	}
+	return errs
+}
+
+// lintFields reports each key in node that isn't a field of t, at the line of the key. It's used instead of a
+// strict yaml.Decoder since that only works on the raw file, where anchors and merge keys haven't been resolved.
+func lintFields(node *yaml.Node, t reflect.Type) []LintFinding {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var findings []LintFinding
+	switch {
+	case node.Kind == yaml.SequenceNode && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array):
+		for _, item := range node.Content {
+			findings = append(findings, lintFields(item, t.Elem())...)
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			field, ok := yamlField(t, key.Value)
+			if !ok {
+				findings = append(findings, LintFinding{Line: key.Line, Severity: LintError, Rule: "unknown-field",
+					Message: fmt.Sprintf("field %s not found in type %s", key.Value, t)})
+				continue
+			}
+			findings = append(findings, lintFields(node.Content[i+1], field.Type)...)
+		}
+	}
+	return findings
+}
+
+// yamlField finds the field of t that has the YAML name
+func yamlField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldName, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if fieldName == "" {
+			fieldName = strings.ToLower(field.Name)
+		}
+		if field.IsExported() && fieldName == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// dependencySet identifies the pull request by the dependencies it updates, e.g. "a@1.0.0, b@2.0.0"
+func dependencySet(output Output) string {
+	data, err := yaml.Marshal(output.Expect.Data)
+	if err != nil {
+		return ""
+	}
+	var pr CreatePullRequest
+	if err = yaml.Unmarshal(data, &pr); err != nil {
+		return ""
+	}
+	var deps []string
+	for _, dep := range pr.Dependencies {
+		version := ""
+		if dep.Version != nil {
+			version = *dep.Version
+		}
+		deps = append(deps, dep.Name+"@"+version)
+	}
+	sort.Strings(deps)
+	return strings.Join(deps, ", ")
+}
@@ -0,0 +1,172 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLintScenario(t *testing.T) {
+	data := `input:
+  job:
+    package-manager: go_modules
+    source:
+      repo: rsc/quote
+    secrity-updates-only: true
+output:
+  - type: mark_as_processed
+    expect:
+      data:
+        base-commit-sha: "1234"
+  - type: update_dependency_list
+    expect:
+      data:
+        dependencies: []
+        dependency_files: []
+  - type: create_pull_request
+    expect:
+      data:
+        dependencies:
+          - name: rsc.io/quote
+            version: 1.5.2
+  - type: create_pull_request
+    expect:
+      data:
+        pr-titel: typo
+        dependencies:
+          - name: rsc.io/quote
+            version: 1.5.2
+  - type: create_pull_request
+    partial-match: true
+    expect:
+      data:
+        dependencies:
+          - name: rsc.io/quote
+            version: 1.5.2
+`
+	findings, err := LintScenario([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []LintFinding{
+		{Line: 6, Severity: LintError, Rule: "unknown-field", Message: "field secrity-updates-only not found in type model.Job"},
+		{Line: 8, Severity: LintWarning, Rule: "output-order", Message: "mark_as_processed is the last call the updater makes"},
+		{Line: 12, Severity: LintWarning, Rule: "output-order", Message: "update_dependency_list is the first call the updater makes"},
+		{Line: 26, Severity: LintError, Rule: "unknown-field", Message: "field pr-titel not found in type model.CreatePullRequest"},
+		{Line: 30, Severity: LintError, Rule: "duplicate-pull-request", Message: "create_pull_request for rsc.io/quote@1.5.2 is expected more than once"},
+	}
+	if !reflect.DeepEqual(findings, want) {
+		t.Errorf("expected:\n%v\ngot:\n%v", want, findings)
+	}
+}
+
+func TestLintOutput(t *testing.T) {
+	createPR := func(branch string) Output {
+		return Output{Type: "create_pull_request", Expect: UpdateWrapper{Data: map[string]any{"branch-name": branch}}}
+	}
+	if errs := LintOutput(createPR("dependabot/go_modules/rsc.io/quote-1.5.2")); len(errs) != 0 {
+		t.Errorf("expected a Dependabot branch to be valid, got %v", errs)
+	}
+	errs := LintOutput(createPR("dependabot/go_modules/quote..1.5.2"))
+	want := `create_pull_request: invalid branch-name: branch "dependabot/go_modules/quote..1.5.2" isn't a valid Git branch name`
+	if len(errs) != 1 || errs[0].Error() != want {
+		t.Errorf("expected %q, got %v", want, errs)
+	}
+	if errs = LintOutput(Output{Type: "create_pull_requests"}); len(errs) != 1 {
+		t.Errorf("expected an unknown type to be invalid, got %v", errs)
+	}
+}
+
+func TestLintScenario_unknownErrorType(t *testing.T) {
+	data := `input:
+  job:
+    package-manager: go_modules
+    source:
+      repo: rsc/quote
+output:
+  - type: record_update_job_error
+    expect:
+      data:
+        error-type: dependency_file_not_fuond
+        error-details: {}
+`
+	findings, err := LintScenario([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LintFinding{
+		{Line: 7, Severity: LintWarning, Rule: "unknown-error-type", Message: `unknown error type "dependency_file_not_fuond"`},
+	}
+	if !reflect.DeepEqual(findings, want) {
+		t.Errorf("expected:\n%v\ngot:\n%v", want, findings)
+	}
+}
+
+func TestLintScenario_clean(t *testing.T) {
+	data := `input:
+  job:
+    package-manager: go_modules
+    source:
+      repo: rsc/quote
+output:
+  - type: update_dependency_list
+    expect:
+      data:
+        dependencies: []
+        dependency_files: []
+  - type: mark_as_processed
+    expect:
+      data:
+        base-commit-sha: "1234"
+`
+	findings, err := LintScenario([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("expected no findings, got %v", findings)
+	}
+}
+
+func TestLintScenario_anchors(t *testing.T) {
+	data := `x-job: &job
+  package-manager: go_modules
+  source:
+    repo: rsc/quote
+x-pr: &pr
+  base-commit-sha: "1234"
+  dependencies: []
+runs:
+  - input:
+      job:
+        <<: *job
+        lockfile-only: true
+    output:
+      - type: create_pull_request
+        expect:
+          data:
+            <<: *pr
+            pr-title: Bump rsc.io/quote
+            updated-dependency-files: []
+  - input:
+      job: *job
+`
+	findings, err := LintScenario([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("expected anchors, aliases and merge keys to be allowed, got %v", findings)
+	}
+
+	var scenario Scenario
+	if err = UnmarshalWithIncludes([]byte(data), "scenario.yml", &scenario); err != nil {
+		t.Fatal(err)
+	}
+	if errs := ValidateScenario(scenario); len(errs) > 0 {
+		t.Errorf("expected the scenario to be valid, got %v", errs)
+	}
+	if job := scenario.Runs[0].Input.Job; job.PackageManager != "go_modules" || !job.LockfileOnly {
+		t.Errorf("expected the merged job, got %+v", job)
+	}
+}
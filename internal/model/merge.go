@@ -0,0 +1,41 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MergeScenarios combines a scenario split across files, e.g. one with the job and one with the expected outputs.
+// Fields the overlay sets take precedence over the base, recursively for structs like the job's source, while lists
+// such as credentials are replaced as a whole. The exception is the outputs and runs, where the overlay's are
+// appended after the base's. A scenario with runs can't be merged with one that has a top level input or output.
+func MergeScenarios(base, overlay Scenario) (Scenario, error) {
+	if len(base.Runs) > 0 && hasTopLevelRun(overlay) || len(overlay.Runs) > 0 && hasTopLevelRun(base) {
+		return Scenario{}, fmt.Errorf("can't merge a scenario with runs and one with a top level input or output")
+	}
+
+	merged := Scenario{Input: base.Input}
+	mergeFields(reflect.ValueOf(&merged.Input).Elem(), reflect.ValueOf(overlay.Input))
+	// cloned so appending to the merged scenario never writes to the base's backing array
+	merged.Output = append(append([]Output(nil), base.Output...), overlay.Output...)
+	merged.Runs = append(append([]Run(nil), base.Runs...), overlay.Runs...)
+	return merged, nil
+}
+
+func hasTopLevelRun(s Scenario) bool {
+	return len(s.Output) > 0 || !reflect.ValueOf(s.Input).IsZero()
+}
+
+// mergeFields sets each field of dst that's set in src, recursing into structs so only the fields set are replaced
+func mergeFields(dst, src reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		field, value := dst.Field(i), src.Field(i)
+		switch {
+		case !field.CanSet() || value.IsZero():
+		case field.Kind() == reflect.Struct:
+			mergeFields(field, value)
+		default:
+			field.Set(value)
+		}
+	}
+}
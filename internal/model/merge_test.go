@@ -0,0 +1,105 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeScenarios(t *testing.T) {
+	strategy := "bump_versions"
+
+	t.Run("input from one file and output from another", func(t *testing.T) {
+		base := Scenario{Input: Input{Job: Job{PackageManager: "npm_and_yarn", Source: Source{Repo: "dependabot/smoke-tests"}}}}
+		overlay := Scenario{Output: []Output{{Type: "mark_as_processed"}}}
+
+		merged, err := MergeScenarios(base, overlay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Scenario{Input: base.Input, Output: overlay.Output}
+		if !reflect.DeepEqual(merged, want) {
+			t.Errorf("expected %+v, got %+v", want, merged)
+		}
+	})
+
+	t.Run("overlay outputs are appended", func(t *testing.T) {
+		base := Scenario{Output: []Output{{Type: "update_dependency_list"}}}
+		overlay := Scenario{Output: []Output{{Type: "mark_as_processed"}}}
+
+		merged, err := MergeScenarios(base, overlay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(merged.Output) != 2 || merged.Output[0].Type != "update_dependency_list" || merged.Output[1].Type != "mark_as_processed" {
+			t.Errorf("expected the base outputs then the overlay's, got %+v", merged.Output)
+		}
+		if len(base.Output) != 1 {
+			t.Errorf("expected the base not to change, got %+v", base.Output)
+		}
+	})
+
+	t.Run("overlay job fields overwrite the base", func(t *testing.T) {
+		base := Scenario{Input: Input{Job: Job{
+			PackageManager: "npm_and_yarn",
+			Dependencies:   []string{"lodash"},
+			Source:         Source{Repo: "dependabot/smoke-tests", Directory: "/"},
+		}}}
+		overlay := Scenario{Input: Input{Job: Job{
+			Dependencies:               []string{"left-pad"},
+			RequirementsUpdateStrategy: &strategy,
+			Source:                     Source{Directory: "/frontend"},
+		}}}
+
+		merged, err := MergeScenarios(base, overlay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Job{
+			PackageManager:             "npm_and_yarn",
+			Dependencies:               []string{"left-pad"},
+			RequirementsUpdateStrategy: &strategy,
+			Source:                     Source{Repo: "dependabot/smoke-tests", Directory: "/frontend"},
+		}
+		if !reflect.DeepEqual(merged.Input.Job, want) {
+			t.Errorf("expected %+v, got %+v", want, merged.Input.Job)
+		}
+	})
+
+	t.Run("overlay credentials replace the base", func(t *testing.T) {
+		base := Scenario{Input: Input{Credentials: []Credential{{"type": "npm_registry"}}}}
+		overlay := Scenario{Input: Input{Credentials: []Credential{{"type": "git_source"}}}}
+
+		merged, err := MergeScenarios(base, overlay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(merged.Input.Credentials, overlay.Input.Credentials) {
+			t.Errorf("expected the overlay credentials, got %+v", merged.Input.Credentials)
+		}
+	})
+
+	t.Run("overlay runs are appended", func(t *testing.T) {
+		base := Scenario{Runs: []Run{{Input: Input{Job: Job{PackageManager: "npm_and_yarn"}}}}}
+		overlay := Scenario{Runs: []Run{{Input: Input{Job: Job{PackageManager: "bundler"}}}}}
+
+		merged, err := MergeScenarios(base, overlay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(merged.Runs) != 2 || merged.Runs[1].Input.Job.PackageManager != "bundler" {
+			t.Errorf("expected both runs, got %+v", merged.Runs)
+		}
+	})
+
+	t.Run("runs can't be merged with a top level run", func(t *testing.T) {
+		base := Scenario{Runs: []Run{{Input: Input{Job: Job{PackageManager: "npm_and_yarn"}}}}}
+		overlay := Scenario{Output: []Output{{Type: "mark_as_processed"}}}
+
+		if _, err := MergeScenarios(base, overlay); err == nil {
+			t.Error("expected an error")
+		}
+		if _, err := MergeScenarios(overlay, base); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
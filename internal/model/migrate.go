@@ -0,0 +1,174 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Migration is a change to the scenario file format that MigrateScenario can apply to older files
+type Migration struct {
+	// Version is the format version the migration upgrades to
+	Version int
+	// Description says what the migration changes, e.g. `move "job" under "input"`
+	Description string
+	// apply changes the document in place and reports whether anything changed.
+	// It must leave an already migrated document alone so migrating is idempotent.
+	apply func(doc *yaml.Node) bool
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("v%d→v%d: %s", m.Version-1, m.Version, m.Description)
+}
+
+// Migrations are every known change to the scenario format, oldest first
+var Migrations = []Migration{
+	{
+		Version:     2,
+		Description: `move top level "job" and "credentials" of a scenario under "input"`,
+		apply:       moveInputFields,
+	},
+	{
+		Version:     3,
+		Description: `nest each entry of "existing-pull-requests" in a list of dependencies`,
+		apply:       nestExistingPullRequests,
+	},
+	{
+		Version:     4,
+		Description: `remove "credentials-metadata" from the job, credentials belong in "input.credentials"`,
+		apply:       removeCredentialsMetadata,
+	},
+}
+
+// MigrateScenario applies every migration that changes the scenario file data,
+// returning the updated file and the migrations that were applied. Comments are preserved.
+func MigrateScenario(data []byte) ([]byte, []Migration, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode scenario: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return data, nil, nil
+	}
+
+	var applied []Migration
+	for _, m := range Migrations {
+		if m.apply(doc.Content[0]) {
+			applied = append(applied, m)
+		}
+	}
+	if len(applied) == 0 {
+		return data, nil, nil
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode scenario: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode scenario: %w", err)
+	}
+	return out.Bytes(), applied, nil
+}
+
+func moveInputFields(scenario *yaml.Node) bool {
+	if mappingIndex(scenario, "output") < 0 && mappingIndex(scenario, "input") < 0 {
+		// without outputs it's an input file for the update command, which has job at the top level
+		return false
+	}
+	var moved []*yaml.Node
+	for _, key := range []string{"job", "credentials"} {
+		if i := mappingIndex(scenario, key); i >= 0 {
+			moved = append(moved, scenario.Content[i], scenario.Content[i+1])
+			scenario.Content = append(scenario.Content[:i], scenario.Content[i+2:]...)
+		}
+	}
+	if len(moved) == 0 {
+		return false
+	}
+
+	input := mappingValue(scenario, "input")
+	if input == nil {
+		input = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "input"}
+		// keep any comment at the top of the file at the top
+		key.HeadComment, moved[0].HeadComment = moved[0].HeadComment, ""
+		scenario.Content = append([]*yaml.Node{key, input}, scenario.Content...)
+	}
+	input.Content = append(input.Content, moved...)
+	return true
+}
+
+func nestExistingPullRequests(scenario *yaml.Node) bool {
+	var changed bool
+	for _, job := range jobNodes(scenario) {
+		prs := mappingValue(job, "existing-pull-requests")
+		if prs == nil || prs.Kind != yaml.SequenceNode {
+			continue
+		}
+		for i, pr := range prs.Content {
+			if pr.Kind == yaml.MappingNode {
+				prs.Content[i] = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{pr}}
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+func removeCredentialsMetadata(scenario *yaml.Node) bool {
+	var changed bool
+	for _, job := range jobNodes(scenario) {
+		if i := mappingIndex(job, "credentials-metadata"); i >= 0 {
+			job.Content = append(job.Content[:i], job.Content[i+2:]...)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// jobNodes returns the job of the scenario and of each of its runs
+func jobNodes(scenario *yaml.Node) []*yaml.Node {
+	var inputs []*yaml.Node
+	if input := mappingValue(scenario, "input"); input != nil {
+		inputs = append(inputs, input)
+	}
+	if runs := mappingValue(scenario, "runs"); runs != nil && runs.Kind == yaml.SequenceNode {
+		for _, run := range runs.Content {
+			if input := mappingValue(run, "input"); input != nil {
+				inputs = append(inputs, input)
+			}
+		}
+	}
+
+	var jobs []*yaml.Node
+	for _, input := range inputs {
+		if job := mappingValue(input, "job"); job != nil && job.Kind == yaml.MappingNode {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// mappingIndex returns the index of key's node in a mapping node's content, or -1 if it isn't there
+func mappingIndex(node *yaml.Node, key string) int {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return -1
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if i := mappingIndex(node, key); i >= 0 {
+		return node.Content[i+1]
+	}
+	return nil
+}
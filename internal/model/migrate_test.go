@@ -0,0 +1,75 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestMigrateScenario(t *testing.T) {
+	old := `# a scenario from before the input section
+job:
+  package-manager: go_modules
+  source:
+    repo: rsc/quote
+  existing-pull-requests:
+    - dependency-name: rsc.io/quote
+      dependency-version: 1.5.2
+  credentials-metadata:
+    - type: git_source
+credentials:
+  - type: git_source
+    host: github.com
+output:
+  - type: mark_as_processed
+    expect:
+      data:
+        base-commit-sha: "1234"
+`
+	want := `# a scenario from before the input section
+input:
+  job:
+    package-manager: go_modules
+    source:
+      repo: rsc/quote
+    existing-pull-requests:
+      - - dependency-name: rsc.io/quote
+          dependency-version: 1.5.2
+  credentials:
+    - type: git_source
+      host: github.com
+output:
+  - type: mark_as_processed
+    expect:
+      data:
+        base-commit-sha: "1234"
+`
+
+	migrated, applied, err := MigrateScenario([]byte(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(migrated) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, migrated)
+	}
+	if len(applied) != len(Migrations) {
+		t.Errorf("expected every migration to be applied, got %v", applied)
+	}
+
+	again, applied, err := MigrateScenario(migrated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 || string(again) != string(migrated) {
+		t.Errorf("expected migrating twice to change nothing, got %v:\n%s", applied, again)
+	}
+}
+
+func TestMigrateScenario_InputFile(t *testing.T) {
+	input := "job:\n  package-manager: go_modules\n"
+	migrated, applied, err := MigrateScenario([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 || string(migrated) != input {
+		t.Errorf("expected an input file to be left alone, got %v:\n%s", applied, migrated)
+	}
+}
@@ -3,23 +3,53 @@ package model
 // Scenario is a way to test a job by asserting the outputs.
 type Scenario struct {
 	// Input is the input parameters
-	Input Input `yaml:"input"`
+	Input Input `json:"input" yaml:"input"`
 	// Output is the list of expected outputs
-	Output []Output `yaml:"output,omitempty"`
+	Output []Output `json:"output,omitempty" yaml:"output,omitempty"`
+	// Runs describes several jobs, e.g. one per package manager, in place of Input and Output
+	Runs []Run `json:"runs,omitempty" yaml:"runs,omitempty"`
+}
+
+// Run is one job in a scenario with multiple runs
+type Run struct {
+	// Input is the input parameters
+	Input Input `json:"input" yaml:"input"`
+	// Output is the list of expected outputs
+	Output []Output `json:"output,omitempty" yaml:"output,omitempty"`
+}
+
+// AllRuns returns each job the scenario describes, whether it uses Runs or a single Input and Output
+func (s *Scenario) AllRuns() []Run {
+	if len(s.Runs) > 0 {
+		return s.Runs
+	}
+	return []Run{{Input: s.Input, Output: s.Output}}
 }
 
 // Input is the input to a job
 type Input struct {
 	// Job is the data given to the updater
-	Job Job `yaml:"job"`
+	Job Job `json:"job" yaml:"job"`
 	// Credentials is the registry info and tokens to pass to the Proxy
-	Credentials []Credential `yaml:"credentials,omitempty"`
+	Credentials []Credential `json:"credentials,omitempty" yaml:"credentials,omitempty"`
 }
 
 // Output is the expected output given the inputs
 type Output struct {
 	// Type is the kind of data to be checked, e.g. update_dependency_list, create_pull_request, etc
-	Type string `yaml:"type"`
+	Type string `json:"type" yaml:"type"`
 	// Expect is the data expected to be sent
-	Expect UpdateWrapper `yaml:"expect"`
+	Expect UpdateWrapper `json:"expect" yaml:"expect"`
+	// Unordered allows this output to arrive in any order relative to adjacent unordered outputs
+	Unordered bool `json:"unordered,omitempty" yaml:"unordered,omitempty"`
+	// PartialMatch only compares the fields that are set in Expect, empty fields match anything
+	PartialMatch bool `json:"partial-match,omitempty" yaml:"partial-match,omitempty"`
+	// SkipIf is a Go template condition on the environment variables, e.g. `not .REGISTRY_URL`,
+	// the output isn't expected when it's true
+	SkipIf string `json:"skip-if,omitempty" yaml:"skip-if,omitempty"`
+	// Match, when set in code, decides whether the output is satisfied instead of comparing to Expect
+	Match func(*UpdateWrapper) bool `yaml:"-" json:"-"`
+	// After, when set in code, is called once the output is matched, e.g. to change a test repo before
+	// the updater continues. It's called while the API is handling the request, so it mustn't call the API.
+	After func() `yaml:"-" json:"-"`
 }
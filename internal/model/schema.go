@@ -0,0 +1,183 @@
+package model
+
+import (
+	"embed"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+)
+
+// the model's source is embedded so the schema can use the doc comments as descriptions
+//
+//go:embed scenario.go job.go update.go
+var modelSource embed.FS
+
+const jsonSchemaDraft07 = "http://json-schema.org/draft-07/schema#"
+
+// ScenarioSchema returns a JSON Schema (draft-07) describing scenario files, suitable for editors
+// like VS Code's YAML extension. Each output's expect.data is described by the schema of its type.
+func ScenarioSchema() map[string]any {
+	g := &schemaGenerator{
+		docs:        typeDocs(),
+		definitions: map[string]any{},
+	}
+	g.schemaFor(reflect.TypeOf(Scenario{}))
+	// the scenario is the root rather than a $ref so the title isn't ignored
+	schema := map[string]any{
+		"$schema": jsonSchemaDraft07,
+		"title":   "Dependabot CLI scenario",
+	}
+	for k, v := range g.definitions["Scenario"].(map[string]any) {
+		schema[k] = v
+	}
+
+	// an output is only valid with the data of its type
+	output := g.definitions["Output"].(map[string]any)
+	kinds := OutputTypes()
+	var conditions []any
+	for _, kind := range kinds {
+		payloadType, _ := lookupOutputType(kind)
+		conditions = append(conditions, map[string]any{
+			"if": map[string]any{
+				"properties": map[string]any{"type": map[string]any{"const": kind}},
+			},
+			"then": map[string]any{
+				"properties": map[string]any{
+					"expect": map[string]any{
+						"properties": map[string]any{"data": g.schemaFor(payloadType)},
+					},
+				},
+			},
+		})
+	}
+	output["properties"].(map[string]any)["type"].(map[string]any)["enum"] = kinds
+	output["allOf"] = conditions
+
+	schema["definitions"] = g.definitions
+	return schema
+}
+
+type schemaGenerator struct {
+	// docs are the doc comments of each type and its fields, keyed by "Type" and "Type.Field"
+	docs        map[string]string
+	definitions map[string]any
+}
+
+func (g *schemaGenerator) schemaFor(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaFor(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		return g.structSchema(t)
+	default:
+		// interfaces can hold anything
+		return map[string]any{}
+	}
+}
+
+// structSchema adds the struct to the definitions the first time it's seen and returns a reference to it
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	ref := map[string]any{"$ref": "#/definitions/" + t.Name()}
+	if _, ok := g.definitions[t.Name()]; ok {
+		return ref
+	}
+
+	properties := map[string]any{}
+	definition := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if doc := g.docs[t.Name()]; doc != "" {
+		definition["description"] = doc
+	}
+	// added before the fields in case the struct refers to itself
+	g.definitions[t.Name()] = definition
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if !field.IsExported() || name == "-" || field.Type.Kind() == reflect.Func {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		property := g.schemaFor(field.Type)
+		if doc := g.docs[t.Name()+"."+field.Name]; doc != "" {
+			if _, isRef := property["$ref"]; isRef {
+				// draft-07 ignores siblings of $ref, so wrap it
+				property = map[string]any{"allOf": []any{property}}
+			}
+			property["description"] = doc
+		}
+		properties[name] = property
+	}
+	return ref
+}
+
+// typeDocs parses the embedded model source for the doc comments of each type and field
+func typeDocs() map[string]string {
+	docs := map[string]string{}
+	files, _ := modelSource.ReadDir(".")
+	for _, file := range files {
+		data, err := modelSource.ReadFile(file.Name())
+		if err != nil {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), file.Name(), data, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				if doc := commentText(typeSpec.Doc, gen.Doc); doc != "" {
+					docs[typeSpec.Name.Name] = doc
+				}
+				structType, ok := typeSpec.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				for _, field := range structType.Fields.List {
+					doc := commentText(field.Doc, field.Comment)
+					for _, name := range field.Names {
+						if doc != "" {
+							docs[typeSpec.Name.Name+"."+name.Name] = doc
+						}
+					}
+				}
+			}
+		}
+	}
+	return docs
+}
+
+// commentText returns the text of the first comment group that's set, on a single line
+func commentText(groups ...*ast.CommentGroup) string {
+	for _, group := range groups {
+		if text := strings.TrimSpace(group.Text()); text != "" {
+			return strings.Join(strings.Fields(text), " ")
+		}
+	}
+	return ""
+}
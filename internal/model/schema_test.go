@@ -0,0 +1,50 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestScenarioSchema(t *testing.T) {
+	data, err := json.Marshal(ScenarioSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Schema      string `json:"$schema"`
+		Definitions map[string]struct {
+			Properties map[string]struct {
+				Ref         string `json:"$ref"`
+				Type        string `json:"type"`
+				Description string `json:"description"`
+			} `json:"properties"`
+			AllOf []struct {
+				If   map[string]any `json:"if"`
+				Then map[string]any `json:"then"`
+			} `json:"allOf"`
+		} `json:"definitions"`
+	}
+	if err = json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	if schema.Schema != jsonSchemaDraft07 {
+		t.Errorf("expected a draft-07 schema, got %q", schema.Schema)
+	}
+	output := schema.Definitions["Output"]
+	if output.Properties["partial-match"].Type != "boolean" {
+		t.Errorf("expected partial-match to be a boolean, got %+v", output.Properties["partial-match"])
+	}
+	if output.Properties["unordered"].Description == "" {
+		t.Error("expected the doc comment to be used as the description")
+	}
+	if _, ok := output.Properties["Match"]; ok {
+		t.Error("expected fields that aren't in YAML to be left out")
+	}
+	if len(output.AllOf) != len(outputTypes) {
+		t.Errorf("expected a condition for each output type, got %d", len(output.AllOf))
+	}
+	if _, ok := schema.Definitions["CreatePullRequest"].Properties["pr-title-pattern"]; !ok {
+		t.Error("expected the output payloads to be defined")
+	}
+}
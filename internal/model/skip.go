@@ -0,0 +1,31 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Skipped evaluates SkipIf with the environment variables in environ, e.g. os.Environ(), as the data.
+// SkipIf is the condition of an if action, so `.NAME` is true when the variable is set and not empty,
+// and `eq .NAME "value"` compares it. Unset variables are empty strings.
+func (o *Output) Skipped(environ []string) (bool, error) {
+	if o.SkipIf == "" {
+		return false, nil
+	}
+	tmpl, err := template.New("skip-if").Option("missingkey=zero").Parse("{{if " + o.SkipIf + "}}true{{end}}")
+	if err != nil {
+		return false, fmt.Errorf("invalid skip-if %q: %w", o.SkipIf, err)
+	}
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			env[name] = value
+		}
+	}
+	var out strings.Builder
+	if err = tmpl.Execute(&out, env); err != nil {
+		return false, fmt.Errorf("invalid skip-if %q: %w", o.SkipIf, err)
+	}
+	return out.String() == "true", nil
+}
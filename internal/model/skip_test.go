@@ -0,0 +1,39 @@
+package model
+
+import "testing"
+
+func TestOutput_Skipped(t *testing.T) {
+	environ := []string{"REGISTRY_URL=https://npm.example.com", "EMPTY=", "MODE=a=b"}
+	tests := []struct {
+		skipIf string
+		want   bool
+	}{
+		{"", false},
+		{".REGISTRY_URL", true},
+		{"not .REGISTRY_URL", false},
+		{".UNSET", false},
+		{"not .UNSET", true},
+		{".EMPTY", false},
+		{`eq .MODE "a=b"`, true},
+		{`eq .UNSET ""`, true},
+		{`and .REGISTRY_URL (ne .MODE "a=b")`, false},
+	}
+	for _, tt := range tests {
+		output := Output{SkipIf: tt.skipIf}
+		got, err := output.Skipped(environ)
+		if err != nil {
+			t.Errorf("%q: %v", tt.skipIf, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %v got %v", tt.skipIf, tt.want, got)
+		}
+	}
+
+	for _, skipIf := range []string{"eq .MODE", "{{ .MODE }}", "nosuchfunc .MODE"} {
+		output := Output{SkipIf: skipIf}
+		if _, err := output.Skipped(environ); err == nil {
+			t.Errorf("%q: expected an error", skipIf)
+		}
+	}
+}
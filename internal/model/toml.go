@@ -0,0 +1,36 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// TOMLToYAML converts a TOML scenario file to YAML, so it's decoded the same way as a YAML one.
+// Converting rather than decoding the TOML directly keeps the output data decoding by type in one place.
+func TOMLToYAML(data []byte) ([]byte, error) {
+	var generic map[string]any
+	if err := toml.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(generic)
+}
+
+// MarshalTOML encodes the scenario as TOML, the inverse of TOMLToYAML
+func MarshalTOML(scenario Scenario) ([]byte, error) {
+	data, err := yaml.Marshal(scenario)
+	if err != nil {
+		return nil, err
+	}
+	var generic map[string]any
+	if err = yaml.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err = toml.NewEncoder(&out).Encode(generic); err != nil {
+		return nil, fmt.Errorf("failed to encode scenario as TOML: %w", err)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMarshalTOML_roundTrip(t *testing.T) {
+	data := []byte(`input:
+  job:
+    package-manager: npm_and_yarn
+    source:
+      repo: dependabot/smoke-tests
+      directory: /
+    experiments:
+      enable-shared-helpers: true
+      timeout: 5
+  credentials:
+    - type: git_source
+      host: github.com
+output:
+  - type: update_dependency_list
+    expect:
+      data:
+        dependencies:
+          - name: left-pad
+            version: 1.0.0
+            requirements:
+              - file: package.json
+                groups: [dependencies]
+                requirement: ^1.0.0
+        dependency_files: [/package.json]
+  - type: create_pull_request
+    expect:
+      data:
+        base-commit-sha: 1234
+        dependencies:
+          - name: left-pad
+            previous-version: 1.0.0
+            version: 1.3.0
+        updated-dependency-files:
+          - name: package.json
+            directory: /
+            content: "{}"
+            type: file
+        pr-title: Bump left-pad from 1.0.0 to 1.3.0
+        commit-message: Bump left-pad
+  - type: mark_as_processed
+    expect:
+      data:
+        base-commit-sha: 1234
+`)
+	var fromYAML Scenario
+	if err := yaml.Unmarshal(data, &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	if len(fromYAML.Output) != 3 || fromYAML.Output[1].Expect.Data == nil {
+		t.Fatalf("expected the outputs to be decoded, got %+v", fromYAML.Output)
+	}
+
+	tomlData, err := MarshalTOML(fromYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yamlData, err := TOMLToYAML(tomlData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromTOML Scenario
+	if err = yaml.Unmarshal(yamlData, &fromTOML); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(fromYAML, fromTOML) {
+		t.Errorf("lost data in a round trip through:\n%s\nexpected %+v\ngot %+v", tomlData, fromYAML, fromTOML)
+	}
+}
+
+func TestTOMLToYAML_invalid(t *testing.T) {
+	if _, err := TOMLToYAML([]byte("input = ")); err == nil {
+		t.Error("expected invalid TOML to be an error")
+	}
+}
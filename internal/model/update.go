@@ -17,6 +17,14 @@ type CreatePullRequest struct {
 	PRBody                 string           `json:"pr-body" yaml:"pr-body,omitempty"`
 	CommitMessage          string           `json:"commit-message" yaml:"commit-message,omitempty"`
 	DependencyGroup        map[string]any   `json:"dependency-group" yaml:"dependency-group,omitempty"`
+	Labels                 []string         `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Reviewers              []string         `json:"reviewers,omitempty" yaml:"reviewers,omitempty"`
+	// PRTitlePattern is only used in expectations, a regular expression the actual PRTitle must match
+	PRTitlePattern string `json:"pr-title-pattern,omitempty" yaml:"pr-title-pattern,omitempty"`
+	// PRBodyPattern is only used in expectations, a regular expression the actual PRBody must match
+	PRBodyPattern string `json:"pr-body-pattern,omitempty" yaml:"pr-body-pattern,omitempty"`
+	// BranchName is the source branch of the pull request, when the updater sends it
+	BranchName string `json:"branch-name,omitempty" yaml:"branch-name,omitempty"`
 }
 
 type UpdatePullRequest struct {
@@ -27,6 +35,9 @@ type UpdatePullRequest struct {
 	PRBody                 string           `json:"pr-body" yaml:"pr-body,omitempty"`
 	CommitMessage          string           `json:"commit-message" yaml:"commit-message,omitempty"`
 	DependencyGroup        map[string]any   `json:"dependency-group" yaml:"dependency-group,omitempty"`
+	// PRNumber is the number of the pull request being updated. An expectation with only PRNumber set
+	// matches any update of that pull request.
+	PRNumber int `json:"pr-number,omitempty" yaml:"pr-number,omitempty"`
 }
 
 type DependencyFile struct {
@@ -40,6 +51,12 @@ type DependencyFile struct {
 	SymlinkTarget   string `json:"symlink_target,omitempty" yaml:"symlink_target,omitempty"`
 	Type            string `json:"type" yaml:"type"`
 	Mode            string `json:"mode" yaml:"mode,omitempty"`
+	// ContentHash, when set in an expectation, is the hex SHA-256 of the file's content, which is compared
+	// instead of Content. Recordings use it for large files so they don't hold the whole content.
+	ContentHash string `json:"content_hash,omitempty" yaml:"content_hash,omitempty"`
+	// BinaryContent is the decoded Content of a file whose ContentEncoding is base64, e.g. an image.
+	// It's set when the API decodes a payload and is never serialized, only the Content is.
+	BinaryContent []byte `json:"-" yaml:"-"`
 }
 
 type ClosePullRequest struct {
@@ -47,6 +64,15 @@ type ClosePullRequest struct {
 	Reason          string   `json:"reason" yaml:"reason"`
 }
 
+// ValidClosePRReasons are the reasons the updater gives for closing a pull request
+var ValidClosePRReasons = map[string]bool{
+	"dependencies_changed":      true,
+	"dependency_group_empty":    true,
+	"dependency_removed":        true,
+	"up_to_date":                true,
+	"update_no_longer_possible": true,
+}
+
 type MarkAsProcessed struct {
 	BaseCommitSha string `json:"base-commit-sha" yaml:"base-commit-sha"`
 }
@@ -60,11 +86,58 @@ type RecordUpdateJobError struct {
 	ErrorDetails map[string]any `json:"error-details" yaml:"error-details"`
 }
 
+// KnownErrorTypes are the error types the updater reports with record_update_job_error
+var KnownErrorTypes = map[string]bool{
+	"all_versions_ignored":                    true,
+	"branch_not_found":                        true,
+	"dependency_file_not_evaluatable":         true,
+	"dependency_file_not_found":               true,
+	"dependency_file_not_parseable":           true,
+	"dependency_file_not_resolvable":          true,
+	"dependency_file_not_supported":           true,
+	"dependency_not_found":                    true,
+	"directory_not_found":                     true,
+	"git_dependencies_not_reachable":          true,
+	"git_dependency_reference_not_found":      true,
+	"go_module_path_mismatch":                 true,
+	"illformed_requirement":                   true,
+	"inconsistent_registry_response":          true,
+	"invalid_git_authentication_credentials":  true,
+	"job_repo_not_found":                      true,
+	"misconfigured_tooling":                   true,
+	"missing_environment_variable":            true,
+	"octokit_rate_limited":                    true,
+	"out_of_disk":                             true,
+	"out_of_memory":                           true,
+	"path_dependencies_not_reachable":         true,
+	"private_source_authentication_failure":   true,
+	"private_source_bad_response":             true,
+	"private_source_certificate_failure":      true,
+	"private_source_timed_out":                true,
+	"pull_request_exists_for_latest_version":  true,
+	"pull_request_exists_for_security_update": true,
+	"security_update_not_found":               true,
+	"security_update_not_needed":              true,
+	"security_update_not_possible":            true,
+	"server_error":                            true,
+	"tool_feature_not_supported":              true,
+	"tool_version_not_supported":              true,
+	"transitive_update_not_possible":          true,
+	"unknown_error":                           true,
+	"update_not_possible":                     true,
+}
+
 type RecordUpdateJobUnknownError struct {
 	ErrorType    string         `json:"error-type" yaml:"error-type"`
 	ErrorDetails map[string]any `json:"error-details" yaml:"error-details"`
 }
 
+type RecordUpdateJobWarning struct {
+	WarnType        string `json:"warn-type" yaml:"warn-type"`
+	WarnTitle       string `json:"warn-title" yaml:"warn-title"`
+	WarnDescription string `json:"warn-description" yaml:"warn-description"`
+}
+
 type IncrementMetric struct {
 	Metric string         `json:"metric" yaml:"metric"`
 	Tags   map[string]any `json:"tags" yaml:"tags"`
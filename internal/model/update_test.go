@@ -0,0 +1,125 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestOutputTypes_roundTrip(t *testing.T) {
+	version, requirement, previousVersion := "1.1.0", "^1.1.0", "1.0.0"
+	dependency := Dependency{
+		Name: "lodash",
+		PreviousRequirements: &[]Requirement{{
+			File:        "package.json",
+			Groups:      []any{"dependencies"},
+			Requirement: &previousVersion,
+			Source:      &RequirementSource{"type": "registry"},
+		}},
+		PreviousVersion: previousVersion,
+		Requirements: []Requirement{{
+			File:            "package.json",
+			Groups:          []any{"dependencies"},
+			Metadata:        &map[string]any{"dev": "false"},
+			Requirement:     &requirement,
+			Source:          &RequirementSource{"type": "registry"},
+			Version:         version,
+			PreviousVersion: previousVersion,
+		}},
+		Version:   &version,
+		Removed:   true,
+		Ecosystem: "npm",
+	}
+	file := DependencyFile{
+		Content:         "{}",
+		ContentEncoding: "utf-8",
+		Deleted:         true,
+		Directory:       "/",
+		Name:            "package.json",
+		Operation:       "update",
+		SupportFile:     true,
+		SymlinkTarget:   "other.json",
+		Type:            "file",
+		Mode:            "100644",
+		ContentHash:     "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a",
+	}
+	details := map[string]any{"message": "failed", "dependencies": []any{"lodash"}}
+
+	payloads := map[string]any{
+		"update_dependency_list": UpdateDependencyList{
+			Dependencies:    []Dependency{dependency},
+			DependencyFiles: []string{"/package.json"},
+		},
+		"create_pull_request": CreatePullRequest{
+			BaseCommitSha:          "1234",
+			Dependencies:           []Dependency{dependency},
+			UpdatedDependencyFiles: []DependencyFile{file},
+			PRTitle:                "Bump lodash",
+			PRBody:                 "Bumps lodash from 1.0.0 to 1.1.0.",
+			CommitMessage:          "Bump lodash",
+			DependencyGroup:        map[string]any{"name": "npm"},
+			Labels:                 []string{"dependencies"},
+			Reviewers:              []string{"octocat"},
+			PRTitlePattern:         "^Bump",
+			PRBodyPattern:          "^Bumps",
+			BranchName:             "dependabot/npm_and_yarn/lodash-1.1.0",
+		},
+		"update_pull_request": UpdatePullRequest{
+			BaseCommitSha:          "1234",
+			DependencyNames:        []string{"lodash"},
+			UpdatedDependencyFiles: []DependencyFile{file},
+			PRTitle:                "Bump lodash",
+			PRBody:                 "Bumps lodash from 1.0.0 to 1.1.0.",
+			CommitMessage:          "Bump lodash",
+			DependencyGroup:        map[string]any{"name": "npm"},
+			PRNumber:               42,
+		},
+		"close_pull_request": ClosePullRequest{
+			DependencyNames: []string{"lodash"},
+			Reason:          "up_to_date",
+		},
+		"mark_as_processed": MarkAsProcessed{BaseCommitSha: "1234"},
+		"record_ecosystem_versions": RecordEcosystemVersions{
+			EcosystemVersions: map[string]any{"package_managers": map[string]any{"npm": "10"}},
+		},
+		"record_update_job_error": RecordUpdateJobError{
+			ErrorType:    "dependency_file_not_found",
+			ErrorDetails: details,
+		},
+		"record_update_job_unknown_error": RecordUpdateJobUnknownError{
+			ErrorType:    "unknown_error",
+			ErrorDetails: details,
+		},
+		"record_update_job_warning": RecordUpdateJobWarning{
+			WarnType:        "deprecated",
+			WarnTitle:       "Deprecated",
+			WarnDescription: "npm 6 is deprecated",
+		},
+		"increment_metric": IncrementMetric{
+			Metric: "updater.started",
+			Tags:   map[string]any{"package_manager": "npm"},
+		},
+	}
+
+	for kind := range outputTypes {
+		if _, ok := payloads[kind]; !ok {
+			t.Errorf("output type %s has no round trip test", kind)
+		}
+	}
+	for kind, payload := range payloads {
+		t.Run(kind, func(t *testing.T) {
+			data, err := yaml.Marshal(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded := reflect.New(reflect.TypeOf(payload))
+			if err = yaml.Unmarshal(data, decoded.Interface()); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(payload, decoded.Elem().Interface()) {
+				t.Errorf("lost data in a round trip through:\n%s\nexpected %+v\ngot %+v", data, payload, decoded.Elem().Interface())
+			}
+		})
+	}
+}
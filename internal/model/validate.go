@@ -0,0 +1,206 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// outputTypesMu guards outputTypes since RegisterOutputSchema can be called while scenarios are validated
+var outputTypesMu sync.RWMutex
+
+// outputTypes maps each output type to the payload its expectations must decode as
+var outputTypes = map[string]reflect.Type{
+	"update_dependency_list":          reflect.TypeOf(UpdateDependencyList{}),
+	"create_pull_request":             reflect.TypeOf(CreatePullRequest{}),
+	"update_pull_request":             reflect.TypeOf(UpdatePullRequest{}),
+	"close_pull_request":              reflect.TypeOf(ClosePullRequest{}),
+	"mark_as_processed":               reflect.TypeOf(MarkAsProcessed{}),
+	"record_ecosystem_versions":       reflect.TypeOf(RecordEcosystemVersions{}),
+	"record_update_job_error":         reflect.TypeOf(RecordUpdateJobError{}),
+	"record_update_job_unknown_error": reflect.TypeOf(RecordUpdateJobUnknownError{}),
+	"record_update_job_warning":       reflect.TypeOf(RecordUpdateJobWarning{}),
+	"increment_metric":                reflect.TypeOf(IncrementMetric{}),
+}
+
+// OutputTypes returns the type of each call the updater makes that a scenario can expect, sorted
+func OutputTypes() []string {
+	outputTypesMu.RLock()
+	defer outputTypesMu.RUnlock()
+	kinds := make([]string, 0, len(outputTypes))
+	for kind := range outputTypes {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// RegisterOutputSchema lets ValidateScenario accept an output type that isn't built in
+func RegisterOutputSchema(kind string, payloadType reflect.Type) {
+	outputTypesMu.Lock()
+	defer outputTypesMu.Unlock()
+	outputTypes[kind] = payloadType
+}
+
+// lookupOutputType returns the payload type registered for kind
+func lookupOutputType(kind string) (reflect.Type, bool) {
+	outputTypesMu.RLock()
+	defer outputTypesMu.RUnlock()
+	payloadType, ok := outputTypes[kind]
+	return payloadType, ok
+}
+
+// ValidateScenario checks the scenario is well-formed so mistakes are found before starting a run
+func ValidateScenario(s Scenario) []error {
+	if len(s.Runs) == 0 {
+		return validateRun(Run{Input: s.Input, Output: s.Output})
+	}
+	var errs []error
+	if s.Input.Job.PackageManager != "" || len(s.Output) > 0 {
+		errs = append(errs, fmt.Errorf("input and output can't be used with runs"))
+	}
+	for i, run := range s.Runs {
+		for _, err := range validateRun(run) {
+			errs = append(errs, fmt.Errorf("runs[%d].%w", i, err))
+		}
+	}
+	return errs
+}
+
+func validateRun(run Run) []error {
+	var errs []error
+	if run.Input.Job.PackageManager == "" {
+		errs = append(errs, fmt.Errorf("input.job.package-manager is required"))
+	}
+	if run.Input.Job.Source.Repo == "" {
+		errs = append(errs, fmt.Errorf("input.job.source.repo is required"))
+	}
+	for i, output := range run.Output {
+		if output.Type == "" && output.Expect.Data == nil {
+			// an empty entry, as in a scenario that's still being written
+			continue
+		}
+		if err := validateOutput(output); err != nil {
+			errs = append(errs, fmt.Errorf("output[%d]: %w", i, err))
+		}
+	}
+	return errs
+}
+
+func validateOutput(output Output) error {
+	if output.Type == "" {
+		return fmt.Errorf("type is required")
+	}
+	payloadType, ok := lookupOutputType(output.Type)
+	if !ok {
+		return fmt.Errorf("unknown type %q", output.Type)
+	}
+	if output.Expect.Data == nil {
+		if output.Match != nil {
+			return nil
+		}
+		return fmt.Errorf("%s: expect.data is required", output.Type)
+	}
+	data, err := yaml.Marshal(output.Expect.Data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", output.Type, err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(reflect.New(payloadType).Interface()); err != nil {
+		return fmt.Errorf("%s: %w", output.Type, err)
+	}
+	return nil
+}
+
+// packageManagerVersion is X.Y.Z with optional minor and patch, allowing a leading v and semver suffixes
+var packageManagerVersion = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+){0,2}([-+][0-9A-Za-z.+-]+)?$`)
+
+// ValidateEcosystemVersions checks that each package manager version the updater records is a version,
+// so a malformed one fails with a clear error rather than a confusing mismatch
+func ValidateEcosystemVersions(v RecordEcosystemVersions) error {
+	packageManagers, ok := v.EcosystemVersions["package_managers"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	names := make([]string, 0, len(packageManagers))
+	for name := range packageManagers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		version, ok := packageManagers[name].(string)
+		if !ok {
+			return fmt.Errorf("version of package manager %s must be a string, got %v", name, packageManagers[name])
+		}
+		if !packageManagerVersion.MatchString(version) {
+			return fmt.Errorf("version of package manager %s is malformed: %q", name, version)
+		}
+	}
+	return nil
+}
+
+// ErrShortGitSHA is wrapped by ValidateGitSHA for an abbreviated SHA, which identifies a commit
+// but can't be used as the commit of a job, so callers may prefer to warn about it
+var ErrShortGitSHA = errors.New("short Git SHA")
+
+var hexSHA = regexp.MustCompile(`^[0-9a-f]+$`)
+
+// ValidateGitSHA checks sha is a full Git commit SHA, 40 lowercase hex characters
+func ValidateGitSHA(sha string) error {
+	if !hexSHA.MatchString(sha) {
+		return fmt.Errorf("%q isn't a Git SHA, it must be 40 hex characters", sha)
+	}
+	if len(sha) < 40 {
+		return fmt.Errorf("%w: %q is %d characters, a full SHA is 40", ErrShortGitSHA, sha, len(sha))
+	}
+	if len(sha) > 40 {
+		return fmt.Errorf("%q isn't a Git SHA, it's %d characters rather than 40", sha, len(sha))
+	}
+	return nil
+}
+
+// DefaultBranchPattern is Dependabot's default naming, dependabot/<ecosystem>/<rest> where the rest is usually
+// the dependency and version. A job with a pull-request-branch-name prefix or separator names branches differently.
+var DefaultBranchPattern = regexp.MustCompile(`^dependabot/[a-z0-9_-]+/.+$`)
+
+// gitBranchChars is a branch name without spaces or any of the characters Git doesn't allow in one
+var gitBranchChars = regexp.MustCompile(`^[^\s~^:?*\[\\]+$`)
+
+// ValidateBranchName checks the source branch of a pull request is a valid Git branch name and, if any
+// patterns are given, that it matches one of them, e.g. DefaultBranchPattern
+func ValidateBranchName(branch string, allowed ...*regexp.Regexp) error {
+	if !gitBranchChars.MatchString(branch) {
+		return fmt.Errorf("branch %q must not be empty or contain spaces or any of ~^:?*[\\", branch)
+	}
+	if strings.Contains(branch, "..") || strings.Contains(branch, "//") || strings.HasPrefix(branch, "/") ||
+		strings.HasSuffix(branch, "/") || strings.HasSuffix(branch, ".lock") || strings.HasSuffix(branch, ".") {
+		return fmt.Errorf("branch %q isn't a valid Git branch name", branch)
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	patterns := make([]string, len(allowed))
+	for i, pattern := range allowed {
+		if pattern.MatchString(branch) {
+			return nil
+		}
+		patterns[i] = pattern.String()
+	}
+	return fmt.Errorf("branch %q doesn't match any of %s", branch, strings.Join(patterns, ", "))
+}
+
+// ValidateErrorType checks the error type of record_update_job_error is one of KnownErrorTypes
+func ValidateErrorType(errorType string) error {
+	if !KnownErrorTypes[errorType] {
+		return fmt.Errorf("unknown error type %q", errorType)
+	}
+	return nil
+}
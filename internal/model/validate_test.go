@@ -0,0 +1,175 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestValidateScenario(t *testing.T) {
+	t.Run("valid scenario", func(t *testing.T) {
+		s := Scenario{
+			Input: Input{Job: Job{PackageManager: "go_modules", Source: Source{Repo: "rsc/quote"}}},
+			Output: []Output{{
+				Type:   "mark_as_processed",
+				Expect: UpdateWrapper{Data: map[string]any{"base-commit-sha": "1234"}},
+			}},
+		}
+		if errs := ValidateScenario(s); len(errs) != 0 {
+			t.Errorf("expected no errors, got %v", errs)
+		}
+	})
+
+	t.Run("reports every problem", func(t *testing.T) {
+		s := Scenario{
+			Output: []Output{
+				{Type: "mark_as_processed", Expect: UpdateWrapper{Data: map[string]any{"base-commit": "1234"}}},
+				{Type: "create_pr"},
+			},
+		}
+		errs := ValidateScenario(s)
+		want := []string{
+			"package-manager is required",
+			"source.repo is required",
+			"output[0]: mark_as_processed: yaml: unmarshal errors:\n  line 1: field base-commit not found",
+			`output[1]: unknown type "create_pr"`,
+		}
+		if len(errs) != len(want) {
+			t.Fatalf("expected %d errors, got %v", len(want), errs)
+		}
+		for i := range want {
+			if !strings.Contains(errs[i].Error(), want[i]) {
+				t.Errorf("expected error %d to contain %q, got %q", i, want[i], errs[i])
+			}
+		}
+	})
+}
+
+func TestValidateEcosystemVersions(t *testing.T) {
+	versions := func(v any) RecordEcosystemVersions {
+		return RecordEcosystemVersions{EcosystemVersions: map[string]any{
+			"package_managers": map[string]any{"gomod": v},
+		}}
+	}
+	for _, valid := range []string{"1", "1.22", "1.22.3", "v2.4.1", "3.0.0-beta.1", "1.0.0+build.5"} {
+		if err := ValidateEcosystemVersions(versions(valid)); err != nil {
+			t.Errorf("expected %q to be valid, got %v", valid, err)
+		}
+	}
+	for _, invalid := range []any{"", "latest", "1.2.3.4", "1.2; rm -rf /", "$(whoami)", 1.22} {
+		if err := ValidateEcosystemVersions(versions(invalid)); err == nil {
+			t.Errorf("expected %v to be invalid", invalid)
+		}
+	}
+	if err := ValidateEcosystemVersions(RecordEcosystemVersions{}); err != nil {
+		t.Errorf("expected no package managers to be valid, got %v", err)
+	}
+}
+
+func TestValidateGitSHA(t *testing.T) {
+	if err := ValidateGitSHA("832e37c1a7a4ef89feb9dc7cfa06f62205191994"); err != nil {
+		t.Errorf("expected a full SHA to be valid, got %v", err)
+	}
+	if err := ValidateGitSHA("832e37c"); !errors.Is(err, ErrShortGitSHA) {
+		t.Errorf("expected a short SHA error, got %v", err)
+	}
+	for _, invalid := range []string{"", "main", "832E37C1A7A4EF89FEB9DC7CFA06F62205191994", "832e37c1a7a4ef89feb9dc7cfa06f62205191994a"} {
+		if err := ValidateGitSHA(invalid); err == nil || errors.Is(err, ErrShortGitSHA) {
+			t.Errorf("expected %q to be invalid, got %v", invalid, err)
+		}
+	}
+}
+
+func TestValidateBranchName(t *testing.T) {
+	for _, valid := range []string{
+		"dependabot/npm_and_yarn/lodash-4.17.21",
+		"dependabot/go_modules/rsc.io/quote-1.5.2",
+		"dependabot/github_actions/actions/checkout-4",
+		// pull-request-branch-name can change the prefix and separator, so only Git's rules apply by default
+		"deps-npm_and_yarn-lodash-4.17.21",
+		"main",
+	} {
+		if err := ValidateBranchName(valid); err != nil {
+			t.Errorf("expected %q to be valid, got %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{
+		"",
+		"dependabot/npm_and_yarn/lodash 4.17.21",
+		"dependabot/npm_and_yarn/lodash..4",
+		"dependabot/npm_and_yarn/lodash.lock",
+		"dependabot/npm_and_yarn//lodash",
+		"dependabot/npm_and_yarn/lodash/",
+		"/dependabot/npm_and_yarn/lodash",
+	} {
+		if err := ValidateBranchName(invalid); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+
+	t.Run("allowed patterns", func(t *testing.T) {
+		separator := regexp.MustCompile(`^dependabot-[a-z0-9_]+-.+$`)
+		if err := ValidateBranchName("dependabot/npm_and_yarn/lodash-4.17.21", DefaultBranchPattern, separator); err != nil {
+			t.Errorf("expected the default naming to be allowed, got %v", err)
+		}
+		if err := ValidateBranchName("dependabot-npm_and_yarn-lodash-4.17.21", DefaultBranchPattern, separator); err != nil {
+			t.Errorf("expected the other separator to be allowed, got %v", err)
+		}
+		err := ValidateBranchName("renovate/npm_and_yarn/lodash-4.17.21", DefaultBranchPattern)
+		want := `branch "renovate/npm_and_yarn/lodash-4.17.21" doesn't match any of ^dependabot/[a-z0-9_-]+/.+$`
+		if err == nil || err.Error() != want {
+			t.Errorf("expected %q, got %v", want, err)
+		}
+	})
+}
+
+func TestValidateErrorType(t *testing.T) {
+	if err := ValidateErrorType("dependency_file_not_found"); err != nil {
+		t.Errorf("expected a known error type to be valid, got %v", err)
+	}
+	if err := ValidateErrorType("dependency_file_not_fuond"); err == nil || err.Error() != `unknown error type "dependency_file_not_fuond"` {
+		t.Errorf("expected an unknown error type error, got %v", err)
+	}
+}
+
+func TestRegisterOutputSchema(t *testing.T) {
+	type custom struct {
+		Name string `yaml:"name"`
+	}
+	var kinds []string
+	for i := 0; i < 10; i++ {
+		kinds = append(kinds, fmt.Sprintf("custom_call_%d", i))
+	}
+	t.Cleanup(func() {
+		outputTypesMu.Lock()
+		defer outputTypesMu.Unlock()
+		for _, kind := range kinds {
+			delete(outputTypes, kind)
+		}
+	})
+
+	// registering while scenarios are validated is safe, go test -race checks it
+	var wg sync.WaitGroup
+	for _, kind := range kinds {
+		wg.Add(2)
+		go func(kind string) {
+			defer wg.Done()
+			RegisterOutputSchema(kind, reflect.TypeOf(custom{}))
+		}(kind)
+		go func() {
+			defer wg.Done()
+			_ = OutputTypes()
+			_ = validateOutput(Output{Type: "mark_as_processed", Expect: UpdateWrapper{Data: map[string]any{"base-commit-sha": "1234"}}})
+		}()
+	}
+	wg.Wait()
+
+	output := Output{Type: kinds[0], Expect: UpdateWrapper{Data: map[string]any{"name": "foo"}}}
+	if err := validateOutput(output); err != nil {
+		t.Errorf("expected a registered type to be valid, got %v", err)
+	}
+}
@@ -1,28 +1,38 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"path"
 	"reflect"
+	"regexp"
 	"runtime"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dependabot/cli/internal/model"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/yaml.v3"
 )
 
-// API intercepts calls to the Dependabot API
+// API intercepts calls to the Dependabot API. The exported fields must not be accessed while
+// the server is handling calls, use the methods such as Snapshot instead.
 type API struct {
 	// Expectations is the list of expectations that haven't been met yet
 	Expectations []model.Output
@@ -30,32 +40,185 @@ type API struct {
 	Errors []error
 	// Actual will contain the scenario output that actually happened after the run is Complete
 	Actual model.Scenario
+	// RecordPath, when set, is where Complete writes Actual as a scenario file
+	RecordPath string
+	// PreviousList is the last update_dependency_list the updater sent, or nil before the first one
+	PreviousList *model.UpdateDependencyList
 
-	server          *http.Server
-	cursor          int
-	hasExpectations bool
-	port            int
-	writer          io.Writer
+	// mu guards Actual, Errors, Expectations, PreviousList, and the unexported run state while the server is running
+	mu                sync.RWMutex
+	server            *http.Server
+	cursor            int
+	met               int
+	matched           []bool
+	skipEvaluated     bool
+	ignoreFields      []string
+	useTLS            bool
+	certPEM           []byte
+	keyPEM            []byte
+	caCert            []byte
+	hasExpectations   bool
+	done              chan struct{}
+	metrics           *apiMetrics
+	dryRun            io.Writer
+	errorHandler      func(error)
+	replayDir         string
+	replaySeq         int
+	metricCounts      map[string]int
+	logger            *slog.Logger
+	kind              string
+	persistPath       string
+	signals           chan os.Signal
+	stopSignals       chan struct{}
+	callLog           []APICall
+	callObservers     []func(APICall)
+	responses         map[string][]response
+	fixedResponses    map[string]response
+	maxBodySize       int64
+	bytesReceived     map[string]int64
+	tracer            trace.Tracer
+	maxRequests       int
+	requests          int
+	semverEquivalence bool
+	strictOptionals   bool
+	strictErrorTypes  bool
+	branchPatterns    []*regexp.Regexp
+	listener          net.Listener
+	socketPath        string
+	requestLogging    bool
+	authToken         string
+	actualHooks       []func(model.Output)
+	commitMessages    *model.CommitMessageConfig
+	debugIn           *bufio.Reader
+	debugOut          io.Writer
+	progress          io.Writer
+	port              int
+	writer            io.Writer
 }
 
-// NewAPI creates a new API instance and starts the server
-func NewAPI(expected []model.Output, writer io.Writer) *API {
-	fakeAPIHost := "127.0.0.1"
-	if runtime.GOOS == "linux" {
-		fakeAPIHost = "0.0.0.0"
+// APIOption configures optional behavior of the API
+type APIOption func(*API)
+
+// WithReadTimeout overrides the server's default read timeout
+func WithReadTimeout(d time.Duration) APIOption {
+	return func(a *API) {
+		a.server.ReadTimeout = d
 	}
-	if os.Getenv("FAKE_API_HOST") != "" {
-		fakeAPIHost = os.Getenv("FAKE_API_HOST")
+}
+
+// WithWriteTimeout overrides the server's default write timeout
+func WithWriteTimeout(d time.Duration) APIOption {
+	return func(a *API) {
+		a.server.WriteTimeout = d
 	}
-	// Bind to port 0 for arbitrary port assignment
-	port := "0"
-	if os.Getenv("FAKE_API_PORT") != "" {
-		port = os.Getenv("FAKE_API_PORT")
+}
+
+// WithIdleTimeout overrides the server's default idle timeout
+func WithIdleTimeout(d time.Duration) APIOption {
+	return func(a *API) {
+		a.server.IdleTimeout = d
 	}
-	l, err := net.Listen("tcp", fakeAPIHost+":"+port)
-	if err != nil {
-		panic(err)
+}
+
+// WithMaxRequests makes the API respond with a 429 and record an error for every call after the first n,
+// to catch an updater that makes far more calls than it should
+func WithMaxRequests(n int) APIOption {
+	return func(a *API) {
+		a.maxRequests = n
+	}
+}
+
+// WithIgnoreFields excludes the given fields from comparisons, e.g. "data.pr-body"
+func WithIgnoreFields(paths ...string) APIOption {
+	return func(a *API) {
+		a.ignoreFields = append(a.ignoreFields, paths...)
+	}
+}
+
+// WithStrictOptionals controls whether an optional field an expectation leaves out, such as reviewers,
+// has to be empty in the call. It's true by default. With false, an optional field is only compared when
+// the expectation sets it, so a scenario doesn't break when the updater starts filling in a new field.
+func WithStrictOptionals(strict bool) APIOption {
+	return func(a *API) {
+		a.strictOptionals = strict
+	}
+}
+
+// WithStrictErrorTypes fails a record_update_job_error call or expectation with an error type
+// that isn't one of model.KnownErrorTypes, rather than only logging a warning
+func WithStrictErrorTypes() APIOption {
+	return func(a *API) {
+		a.strictErrorTypes = true
+	}
+}
+
+// WithBranchPatterns fails a create_pull_request whose branch-name doesn't match any of patterns, e.g.
+// model.DefaultBranchPattern. Without it the branch only has to be a valid Git branch name, since the job's
+// pull-request-branch-name settings can change the prefix and separator.
+func WithBranchPatterns(patterns ...*regexp.Regexp) APIOption {
+	return func(a *API) {
+		a.branchPatterns = append(a.branchPatterns, patterns...)
+	}
+}
+
+// WithDryRun skips checking expectations and instead writes each call to w as YAML, or to stderr if w is nil
+func WithDryRun(w io.Writer) APIOption {
+	return func(a *API) {
+		if w == nil {
+			w = os.Stderr
+		}
+		a.dryRun = w
+	}
+}
+
+// WithErrorHandler is called if the server stops unexpectedly, by default the error is logged and added to Errors
+func WithErrorHandler(fn func(error)) APIOption {
+	return func(a *API) {
+		a.errorHandler = fn
+	}
+}
+
+// WithListener serves the API on l instead of binding a new listener
+func WithListener(l net.Listener) APIOption {
+	return func(a *API) {
+		a.listener = l
+	}
+}
+
+// WithActualHook calls fn with each call as it's added to Actual, before it's checked against the expectations,
+// so tests can assert invariants as the updater runs. fn is called while the API is handling the request and
+// holds its lock, so it mustn't call the API, e.g. Actual or Errors, which would deadlock.
+func WithActualHook(fn func(model.Output)) APIOption {
+	return func(a *API) {
+		a.actualHooks = append(a.actualHooks, fn)
+	}
+}
+
+// WithCommitMessageValidation checks the commit message of each create_pull_request meets cfg,
+// in addition to comparing it to the expectation
+func WithCommitMessageValidation(cfg model.CommitMessageConfig) APIOption {
+	return func(a *API) {
+		a.commitMessages = &cfg
+	}
+}
+
+// WithUnixSocket serves the API on a Unix domain socket at path instead of a TCP port,
+// for sandboxes that only allow sharing a socket through a mounted volume
+func WithUnixSocket(path string) APIOption {
+	return func(a *API) {
+		a.socketPath = path
+	}
+}
+
+// WithStructuredLogger logs errors to l with the kind of call and cursor as separate fields
+func WithStructuredLogger(l *slog.Logger) APIOption {
+	return func(a *API) {
+		a.logger = l
 	}
+}
+
+// NewAPI creates a new API instance and starts the server
+func NewAPI(expected []model.Output, writer io.Writer, opts ...APIOption) *API {
 	server := &http.Server{
 		ReadTimeout:       5 * time.Second,
 		ReadHeaderTimeout: 5 * time.Second,
@@ -68,110 +231,641 @@ func NewAPI(expected []model.Output, writer io.Writer) *API {
 		writer:          writer,
 		cursor:          0,
 		hasExpectations: len(expected) > 0,
-		port:            l.Addr().(*net.TCPAddr).Port,
+		maxBodySize:     DefaultMaxBodySize,
+		strictOptionals: true,
+		tracer:          defaultTracer,
+	}
+	api.errorHandler = func(err error) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		api.pushError(err)
+	}
+	api.resetDone()
+	for _, opt := range opts {
+		opt(api)
+	}
+	server.Handler = api.handler()
+	if api.useTLS {
+		if err := api.configureTLS(); err != nil {
+			panic(err)
+		}
+	}
+
+	if api.persistPath != "" {
+		api.handleSignals()
+	}
+	if api.listener == nil && api.socketPath != "" {
+		l, err := net.Listen("unix", api.socketPath)
+		if err != nil {
+			panic(err)
+		}
+		api.listener = l
+	}
+	if api.listener == nil {
+		api.listener = listen()
+	}
+	switch addr := api.listener.Addr().(type) {
+	case *net.TCPAddr:
+		api.port = addr.Port
+	case *net.UnixAddr:
+		api.port = -1
+		api.socketPath = addr.Name
 	}
-	server.Handler = api
 
 	go func() {
-		if err := server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Fatal(err)
+		serve := server.Serve
+		if api.useTLS {
+			// the certificate is already in the TLSConfig
+			serve = func(l net.Listener) error { return server.ServeTLS(l, "", "") }
+		}
+		if err := serve(api.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			api.errorHandler(fmt.Errorf("fake API server failed: %w", err))
 		}
 	}()
 
 	return api
 }
 
-// Port returns the port the API is listening on
+// listen binds the address the fake API is configured to use by the environment
+func listen() net.Listener {
+	fakeAPIHost := "127.0.0.1"
+	if runtime.GOOS == "linux" {
+		fakeAPIHost = "0.0.0.0"
+	}
+	if os.Getenv("FAKE_API_HOST_IPV6") == "1" {
+		fakeAPIHost = "::1"
+	}
+	if os.Getenv("FAKE_API_HOST") != "" {
+		fakeAPIHost = os.Getenv("FAKE_API_HOST")
+	}
+	// Bind to port 0 for arbitrary port assignment
+	port := "0"
+	if os.Getenv("FAKE_API_PORT") != "" {
+		port = os.Getenv("FAKE_API_PORT")
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort(fakeAPIHost, port))
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// Port returns the port the API is listening on, or -1 if it's listening on a Unix socket
 func (a *API) Port() int {
 	return a.port
 }
 
-// Stop stops the server
+// SocketPath returns the path of the Unix socket the API is listening on, or "" if it's listening on a port
+func (a *API) SocketPath() string {
+	return a.socketPath
+}
+
+// Addr returns the address the API is listening on in host:port format, or "" if it's listening on a
+// Unix socket, use SocketPath for that
+func (a *API) Addr() string {
+	if a.port == -1 {
+		return ""
+	}
+	return a.listener.Addr().String()
+}
+
+// URL returns the base URL of the API, e.g. http://127.0.0.1:8080. On a Unix socket it's http://unix,
+// the host is only a placeholder and clients have to dial SocketPath to reach it.
+func (a *API) URL() string {
+	scheme := "http"
+	if a.useTLS {
+		scheme = "https"
+	}
+	if a.port == -1 {
+		return scheme + "://unix"
+	}
+	return scheme + "://" + a.Addr()
+}
+
+// Stop stops the server, giving in-flight requests 5 seconds to finish
 func (a *API) Stop() {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	a.StopWithTimeout(5 * time.Second)
+}
+
+// StopWithTimeout stops the server, giving in-flight requests up to d to finish
+func (a *API) StopWithTimeout(d time.Duration) {
+	a.stopHandlingSignals()
+	ctx, cancel := context.WithTimeout(context.Background(), d)
 	_ = a.server.Shutdown(ctx)
 	cancel()
 }
 
+// Reset replaces the expectations and clears the results of the previous run, leaving the server running
+func (a *API) Reset(expected []model.Output) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Expectations = expected
+	a.hasExpectations = len(expected) > 0
+	a.cursor = 0
+	a.met = 0
+	a.matched = nil
+	a.skipEvaluated = false
+	a.Errors = nil
+	a.Actual = model.Scenario{}
+	a.metricCounts = nil
+	a.callLog = nil
+	a.requests = 0
+	a.bytesReceived = nil
+	a.PreviousList = nil
+	a.resetDone()
+}
+
+// WaitForCompletion blocks until every expectation has been checked or the context is done.
+// In a dry run nothing is checked, so it returns once the updater calls mark_as_processed.
+func (a *API) WaitForCompletion(ctx context.Context) error {
+	a.mu.RLock()
+	done := a.done
+	a.mu.RUnlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		a.mu.RLock()
+		defer a.mu.RUnlock()
+		return fmt.Errorf("timed out waiting for %d expectations: %w", len(a.Expectations)-a.cursor, ctx.Err())
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if len(a.Errors) > 0 {
+		return fmt.Errorf("expectations were not met: %d errors", len(a.Errors))
+	}
+	return nil
+}
+
+func (a *API) resetDone() {
+	a.done = make(chan struct{})
+	a.checkDone()
+}
+
+// checkDone signals WaitForCompletion once the cursor has passed every expectation
+func (a *API) checkDone() {
+	if a.cursor < len(a.Expectations) {
+		return
+	}
+	select {
+	case <-a.done:
+	default:
+		close(a.done)
+	}
+}
+
+// Metrics returns how many times each metric was incremented by increment_metric calls
+func (a *API) Metrics() map[string]int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	counts := make(map[string]int, len(a.metricCounts))
+	for metric, count := range a.metricCounts {
+		counts[metric] = count
+	}
+	return counts
+}
+
+func (a *API) countMetric(metric model.IncrementMetric) {
+	if a.metricCounts == nil {
+		a.metricCounts = map[string]int{}
+	}
+	a.metricCounts[metric.Metric]++
+}
+
+// Snapshot returns a deep copy of Actual that's safe to use while the server is still handling calls
+func (a *API) Snapshot() model.Scenario {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return deepCopy(a.Actual)
+}
+
+// ExpectationsMet reports whether every expectation was reached and none of them failed
+func (a *API) ExpectationsMet() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if len(a.unmetExpectations()) > 0 {
+		return false
+	}
+	for _, err := range a.Errors {
+		if isExpectationError(err) {
+			return false
+		}
+	}
+	return true
+}
+
+// ErrorCount returns the number of errors in Errors, including expectation failures, decode errors,
+// and problems with the server itself.
+func (a *API) ErrorCount() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.Errors)
+}
+
+// ExpectationErrorCount returns the number of errors caused by the updater's output not matching
+// the expectations, such as an unexpected body or a missing expectation. Expectations that were
+// never reached are only counted once Complete has been called.
+func (a *API) ExpectationErrorCount() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var count int
+	for _, err := range a.Errors {
+		if isExpectationError(err) {
+			count++
+		}
+	}
+	return count
+}
+
+// DecodeErrorCount returns the number of requests whose payload couldn't be decoded, either because
+// the endpoint isn't known or the data doesn't match the model. These are independent of the expectations.
+func (a *API) DecodeErrorCount() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var count int
+	for _, err := range a.Errors {
+		var decodeErr *DecodeError
+		if errors.As(err, &decodeErr) {
+			count++
+		}
+	}
+	return count
+}
+
+// ClearErrors empties Errors, e.g. after a test has checked an error it caused on purpose
+func (a *API) ClearErrors() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Errors = nil
+}
+
+// ClearExpectationErrors removes the errors counted by ExpectationErrorCount,
+// leaving decode errors and problems with the server in place
+func (a *API) ClearExpectationErrors() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Errors = slices.DeleteFunc(a.Errors, isExpectationError)
+}
+
+// UnmetExpectations returns the expectations that were never reached
+func (a *API) UnmetExpectations() []model.Output {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.unmetExpectations()
+}
+
+func (a *API) unmetExpectations() []model.Output {
+	var unmet []model.Output
+	for i := a.cursor; i < len(a.Expectations); i++ {
+		if a.matched != nil && a.matched[i] {
+			continue
+		}
+		unmet = append(unmet, a.Expectations[i])
+	}
+	return unmet
+}
+
 // Complete adds any remaining expectations to the error queue
 func (a *API) Complete() {
-	for i := a.cursor; i < len(a.Expectations); i++ {
-		exp := &a.Expectations[i]
-		a.Errors = append(a.Errors, fmt.Errorf("expectation not met: %v\n%v", exp.Type, exp.Expect))
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.dryRun != nil {
+		// nothing was checked, so there's nothing to report
+		a.cursor = len(a.Expectations)
+	}
+	a.evaluateSkipIf()
+	for _, exp := range a.unmetExpectations() {
+		a.Errors = append(a.Errors, &UnmetExpectationError{Expectation: exp})
+	}
+	if a.RecordPath != "" {
+		if err := a.record(); err != nil {
+			a.pushError(err)
+		}
 	}
 }
 
-// ServeHTTP handles requests to the server
-func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	data, err := io.ReadAll(r.Body)
+// TB is the part of testing.TB MustComplete uses, so the package doesn't import testing
+type TB interface {
+	Helper()
+	Fatalf(format string, args ...any)
+}
+
+// MustComplete calls Complete and fails the test with every error if there are any.
+// Call it from the test goroutine since it calls t.Fatalf.
+func (a *API) MustComplete(t TB) {
+	t.Helper()
+	a.Complete()
+	a.mu.RLock()
+	err := errors.Join(a.Errors...)
+	a.mu.RUnlock()
 	if err != nil {
-		err = fmt.Errorf("failed to read body: %w", err)
-		a.pushError(err)
-		return
+		t.Fatalf("%v", err)
 	}
-	if err = r.Body.Close(); err != nil {
-		err = fmt.Errorf("failed to close body: %w", err)
-		a.pushError(err)
-		return
+}
+
+// record writes Actual to RecordPath so it can be used as the starting point of a new scenario
+func (a *API) record() error {
+	if err := a.persist(a.RecordPath); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
 	}
+	return nil
+}
+
+// ServeHTTP handles requests to the server
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the updater makes one call at a time, so handling them in sequence costs nothing
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.serve(w, r)
+}
+
+// InjectRequest processes payload as if the updater had sent it to the kind endpoint,
+// returning any errors it caused. It makes unit testing expectations possible without an HTTP client.
+func (a *API) InjectRequest(kind string, payload []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	errorCount := len(a.Errors)
+	a.handle(context.Background(), kind, io.NopCloser(bytes.NewReader(payload)), "")
+	return errors.Join(a.Errors[errorCount:]...)
+}
 
+// serve handles a request, the caller must hold the lock
+func (a *API) serve(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(r.URL.String(), "/")
 	kind := parts[len(parts)-1]
-	actual, err := decodeWrapper(kind, data)
+	a.handle(r.Context(), kind, r.Body, r.Header.Get("Content-Type")).write(w)
+}
+
+// handle processes a call to the kind endpoint and returns what to answer it with, the caller must hold the lock
+func (a *API) handle(ctx context.Context, kind string, requestBody io.ReadCloser, contentType string) response {
+	a.kind = kind
+	start, errorCount, callCount := time.Now(), len(a.Errors), len(a.callLog)
+	_, span := a.tracer.Start(ctx, kind, trace.WithSpanKind(trace.SpanKindServer))
+	defer func() {
+		a.metrics.observe(kind, start, len(a.Errors) > errorCount)
+		a.endSpan(span, callCount, errorCount)
+	}()
+
+	a.requests++
+	if a.maxRequests > 0 && a.requests > a.maxRequests {
+		_, _ = io.Copy(io.Discard, requestBody)
+		_ = requestBody.Close()
+		err := fmt.Errorf("too many requests: received %d calls, the limit is %d", a.requests, a.maxRequests)
+		a.pushError(err)
+		return errorResponse(http.StatusTooManyRequests, err)
+	}
+
+	if queued, ok := a.nextQueued(kind); ok {
+		_, _ = io.Copy(io.Discard, requestBody)
+		_ = requestBody.Close()
+		return queued
+	}
+
+	limited := newLimitedBody(ctx, requestBody, a.maxBodySize)
+	var body io.Reader = limited
+	var raw bytes.Buffer
+	if a.replayDir != "" {
+		body = io.TeeReader(limited, &raw)
+	}
+
+	// decode straight from the body so large payloads aren't buffered twice
+	actual, err := decodeWrapper(kind, body, bodyDecoderFor(contentType))
+	if limited.exceeded {
+		err = fmt.Errorf("request body for %s exceeds the limit of %d bytes", kind, a.maxBodySize)
+	} else if ctxErr := ctx.Err(); ctxErr != nil {
+		err = fmt.Errorf("request for %s was cancelled while reading the body: %w", kind, ctxErr)
+	}
 	if err != nil {
+		err = &DecodeError{Kind: kind, Err: err}
 		a.pushError(err)
 	}
+	if actual != nil && !limited.exceeded {
+		// the decoder may stop short of the end of the body
+		_, _ = io.Copy(io.Discard, body)
+	}
+	a.recordBytesReceived(kind, limited.read)
+	a.metrics.observeBodySize(kind, limited.read)
+	// metrics aren't expectations and a body that failed to decode can't be replayed, so neither is written
+	if a.replayDir != "" && actual != nil && err == nil && kind != "increment_metric" {
+		a.writeReplay(kind, raw.Bytes())
+	}
+	if closeErr := requestBody.Close(); closeErr != nil {
+		closeErr = fmt.Errorf("failed to close body: %w", closeErr)
+		a.pushError(closeErr)
+		return response{}
+	}
+
+	if limited.exceeded {
+		return errorResponse(http.StatusRequestEntityTooLarge, err)
+	}
 
 	if actual == nil {
 		// indicates the kind (endpoint) isn't implemented in decodeWrapper, so return a 501
-		w.WriteHeader(http.StatusNotImplemented)
-		return
+		return response{status: http.StatusNotImplemented}
+	}
+
+	if err != nil {
+		// the real API rejects payloads it can't decode, so the updater should see the failure too
+		return errorResponse(http.StatusUnprocessableEntity, err)
 	}
 
+	a.logCall(kind, start, actual.Data)
+	// the response SetResponse configured, or an empty 200, whatever happens to the call from here
+	reply := a.fixedResponses[kind]
+
 	if kind == "increment_metric" {
-		// Let's just output the metrics data and stop
+		// Let's just count and output the metrics data and stop
+		a.countMetric(actual.Data.(model.IncrementMetric))
 		a.outputRequestData(kind, actual)
-		return
+		return reply
 	}
 
-	if err := a.pushResult(kind, actual); err != nil {
+	if err := a.recordActual(kind, actual); err != nil {
 		a.pushError(err)
-		return
+		return reply
+	}
+
+	if a.dryRun != nil {
+		a.outputDryRun(kind, actual)
+		if kind == "mark_as_processed" {
+			// the updater's last call, the expectations aren't checked so there's nothing left to wait for
+			a.cursor = len(a.Expectations)
+			a.checkDone()
+		}
+		return reply
 	}
 
 	if !a.hasExpectations {
 		a.outputRequestData(kind, actual)
-		return
+		return reply
 	}
 
 	a.assertExpectation(kind, actual)
+	return reply
+}
+
+func (a *API) outputDryRun(kind string, actual *model.UpdateWrapper) {
+	data, err := yaml.Marshal([]model.Output{{Type: kind, Expect: *actual}})
+	if err != nil {
+		log.Println("Failed to marshal dry run output: ", err)
+		return
+	}
+	_, _ = a.dryRun.Write(data)
 }
 
 func (a *API) assertExpectation(kind string, actual *model.UpdateWrapper) {
+	a.evaluateSkipIf()
+	if a.debugIn != nil && a.debugPrompt(kind, actual) {
+		a.skipExpectation()
+		return
+	}
 	if len(a.Expectations) <= a.cursor {
-		err := fmt.Errorf("missing expectation")
-		a.pushError(err)
+		a.pushError(&MissingExpectationError{Kind: kind})
+		return
+	}
+	if a.Expectations[a.cursor].Unordered {
+		a.assertUnorderedExpectation(kind, actual)
 		return
 	}
-	expect := &a.Expectations[a.cursor]
+	index := a.cursor
+	expect := &a.Expectations[index]
 	a.cursor++
-	if kind != expect.Type {
-		err := fmt.Errorf("type was unexpected: expected %v got %v", expect.Type, kind)
+	// skipped expectations are already marked as matched
+	for a.matched != nil && a.cursor < len(a.Expectations) && a.matched[a.cursor] {
+		a.cursor++
+	}
+	err := a.matchExpectation(expect, kind, actual)
+	a.reportProgress(index, kind, err)
+	if err != nil {
 		a.pushError(err)
-		return
+	} else {
+		a.met++
+		if expect.After != nil {
+			expect.After()
+		}
+	}
+	a.checkDone()
+}
+
+// assertUnorderedExpectation matches the actual against any pending expectation in the run of
+// unordered expectations at the cursor. The cursor only moves past the run once all of it is matched.
+func (a *API) assertUnorderedExpectation(kind string, actual *model.UpdateWrapper) {
+	if a.matched == nil {
+		a.matched = make([]bool, len(a.Expectations))
+	}
+	var firstErr error
+	firstIndex := a.cursor
+	for i := a.cursor; i < len(a.Expectations) && a.Expectations[i].Unordered; i++ {
+		if a.matched[i] {
+			continue
+		}
+		err := a.matchExpectation(&a.Expectations[i], kind, actual)
+		if err == nil {
+			a.matched[i] = true
+			a.met++
+			a.reportProgress(i, kind, nil)
+			if a.Expectations[i].After != nil {
+				a.Expectations[i].After()
+			}
+			for a.cursor < len(a.Expectations) && a.matched[a.cursor] {
+				a.cursor++
+			}
+			a.checkDone()
+			return
+		}
+		if firstErr == nil && kind == a.Expectations[i].Type {
+			firstErr, firstIndex = err, i
+		}
+	}
+	if firstErr == nil {
+		firstErr = &UnexpectedTypeError{Actual: kind}
+	}
+	a.reportProgress(firstIndex, kind, firstErr)
+	a.pushError(firstErr)
+}
+
+func (a *API) matchExpectation(expect *model.Output, kind string, actual *model.UpdateWrapper) error {
+	if expect.Match != nil {
+		if !expect.Match(actual) {
+			return &ExpectationMismatchError{Kind: kind, Err: fmt.Errorf("expectation predicate did not match %v", kind)}
+		}
+		return nil
+	}
+	if kind != expect.Type {
+		return &UnexpectedTypeError{Expected: expect.Type, Actual: kind}
+	}
+	if createPR, ok := actual.Data.(model.CreatePullRequest); ok && a.commitMessages != nil {
+		if err := model.ValidateCommitMessage(createPR.CommitMessage, *a.commitMessages); err != nil {
+			return &ExpectationMismatchError{Kind: kind, Err: fmt.Errorf("invalid commit message for create_pull_request: %w", err)}
+		}
 	}
 	// need to use decodeWrapper to get the right type to match the actual type
 	data, err := json.Marshal(expect.Expect)
 	if err != nil {
 		panic(err)
 	}
-	expected, err := decodeWrapper(expect.Type, data)
+	// decoded as YAML like the scenario file it came from, so e.g. a number is accepted for a string field
+	expected, err := decodeWrapper(expect.Type, bytes.NewReader(data), decodeYAML)
 	if err != nil {
 		panic(err)
 	}
-	if err = compare(expected, actual); err != nil {
-		a.pushError(err)
+	if expectError, ok := expected.Data.(model.RecordUpdateJobError); ok {
+		actualError, _ := actual.Data.(model.RecordUpdateJobError)
+		err = errors.Join(a.checkErrorType("expectation", expectError.ErrorType), a.checkErrorType("call", actualError.ErrorType))
+		if err != nil {
+			return &ExpectationMismatchError{Kind: kind, Err: err}
+		}
+	}
+	// checked before any fields are masked, so a wildcard in the expectation doesn't hide or fake a bad value
+	invalidErr := a.validateActual(actual)
+	if len(a.ignoreFields) > 0 {
+		expected.Data = withoutFields(expected.Data, a.ignoreFields)
+		actual = &model.UpdateWrapper{Data: withoutFields(actual.Data, a.ignoreFields)}
+	}
+	if wildcards := nullFields(expect.Expect.Data, reflect.TypeOf(expected.Data), "data"); len(wildcards) > 0 {
+		// null in a scenario means any value is fine for fields that can't be nil
+		actual = &model.UpdateWrapper{Data: withoutFields(actual.Data, wildcards)}
+	}
+	if expect.PartialMatch {
+		actual = &model.UpdateWrapper{Data: onlyFieldsSetIn(expected.Data, actual.Data)}
+	}
+	if !a.strictOptionals {
+		actual = &model.UpdateWrapper{Data: onlyOptionalFieldsSetIn(expected.Data, actual.Data)}
+	}
+	if a.semverEquivalence {
+		actual = &model.UpdateWrapper{Data: alignEquivalentVersions(expected.Data, actual.Data)}
+	}
+	if err := errors.Join(compare(expected, actual), invalidErr); err != nil {
+		return &ExpectationMismatchError{Kind: kind, Err: err}
+	}
+	return nil
+}
+
+// validateActual checks the values a call sends whatever the expectation is, it's given the call as received
+func (a *API) validateActual(actual *model.UpdateWrapper) error {
+	switch v := actual.Data.(type) {
+	case model.CreatePullRequest:
+		if v.BranchName == "" {
+			return nil
+		}
+		if err := model.ValidateBranchName(v.BranchName, a.branchPatterns...); err != nil {
+			return fmt.Errorf("invalid branch-name for create_pull_request: %w", err)
+		}
+	case model.MarkAsProcessed:
+		err := model.ValidateGitSHA(v.BaseCommitSha)
+		if errors.Is(err, model.ErrShortGitSHA) {
+			// recordActual has warned about it
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid base-commit-sha for mark_as_processed: %w", err)
+		}
+	case model.ClosePullRequest:
+		if !model.ValidClosePRReasons[v.Reason] {
+			return fmt.Errorf("unknown close reason %q sent to close_pull_request", v.Reason)
+		}
 	}
+	return nil
 }
 
 func (a *API) outputRequestData(kind string, actual *model.UpdateWrapper) {
@@ -188,83 +882,246 @@ func (a *API) outputRequestData(kind string, actual *model.UpdateWrapper) {
 }
 
 func (a *API) pushError(err error) {
+	if a.logger != nil {
+		a.logger.Error("fake API error", "kind", a.kind, "cursor", a.cursor, "error", err.Error())
+		a.Errors = append(a.Errors, err)
+		return
+	}
 	escapedError := strings.ReplaceAll(err.Error(), "\n", "")
 	escapedError = strings.ReplaceAll(escapedError, "\r", "")
 	log.Println(escapedError)
 	a.Errors = append(a.Errors, err)
 }
 
-func (a *API) pushResult(kind string, actual *model.UpdateWrapper) error {
+// recordActual adds the call to Actual, which is what the updater did as opposed to what was expected
+func (a *API) recordActual(kind string, actual *model.UpdateWrapper) error {
 	// TODO validate required data
 	output := model.Output{
 		Type:   kind,
 		Expect: *actual,
 	}
+	switch data := actual.Data.(type) {
+	case model.CreatePullRequest:
+		data.UpdatedDependencyFiles = hashLargeFiles(data.UpdatedDependencyFiles)
+		output.Expect.Data = data
+	case model.UpdatePullRequest:
+		data.UpdatedDependencyFiles = hashLargeFiles(data.UpdatedDependencyFiles)
+		output.Expect.Data = data
+	}
 	a.Actual.Output = append(a.Actual.Output, output)
 
+	if list, ok := actual.Data.(model.UpdateDependencyList); ok {
+		a.checkRemovedDependencies(list)
+	}
+	if jobError, ok := actual.Data.(model.RecordUpdateJobError); ok && a.checksCalls() {
+		// with expectations it's checked along with the expectation
+		if err := a.checkErrorType("call", jobError.ErrorType); err != nil {
+			return err
+		}
+	}
+
 	if msg, ok := actual.Data.(model.MarkAsProcessed); ok {
-		// record the commit SHA so the test is reproducible
-		a.Actual.Input.Job.Source.Commit = msg.BaseCommitSha
+		// record the commit SHA so the test is reproducible, unless it's malformed and would corrupt the scenario
+		err := model.ValidateGitSHA(msg.BaseCommitSha)
+		if errors.Is(err, model.ErrShortGitSHA) {
+			log.Printf("warning: base-commit-sha of mark_as_processed is short: %v", err)
+			err = nil
+		}
+		if err == nil {
+			a.Actual.Input.Job.Source.Commit = msg.BaseCommitSha
+		} else if a.checksCalls() {
+			// with expectations validateActual reports it
+			return fmt.Errorf("invalid base-commit-sha for mark_as_processed: %w", err)
+		}
+	}
+
+	for _, hook := range a.actualHooks {
+		hook(output)
+	}
+	return nil
+}
+
+// checksCalls reports whether recordActual checks the values a call sends itself. With expectations they're
+// checked along with the expectation, and a dry run doesn't check anything.
+func (a *API) checksCalls() bool {
+	return !a.hasExpectations && a.dryRun == nil
+}
+
+// checkRemovedDependencies warns about dependencies the previous update_dependency_list had that list doesn't,
+// which is more likely a resolver bug than an intentional removal. It's only a warning since it can be either.
+func (a *API) checkRemovedDependencies(list model.UpdateDependencyList) {
+	if a.PreviousList != nil {
+		for _, name := range DetectRemovedDependencies(*a.PreviousList, list) {
+			a.warn("dependency removed from update_dependency_list", "dependency", name)
+		}
 	}
+	a.PreviousList = &list
+}
 
+// checkErrorType warns about an error type of record_update_job_error that isn't known, or returns an error
+// for it with WithStrictErrorTypes. The source is where the error type came from, the call or the expectation.
+func (a *API) checkErrorType(source, errorType string) error {
+	err := model.ValidateErrorType(errorType)
+	if err == nil {
+		return nil
+	}
+	if a.strictErrorTypes {
+		return fmt.Errorf("%s for record_update_job_error: %w", source, err)
+	}
+	a.warn("unknown error type for record_update_job_error", "source", source, "error_type", errorType)
 	return nil
 }
 
-func decodeWrapper(kind string, data []byte) (actual *model.UpdateWrapper, err error) {
+// warn logs to the structured logger, or slog's default logger if WithStructuredLogger isn't used
+func (a *API) warn(msg string, args ...any) {
+	logger := a.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Warn(msg, args...)
+}
+
+func decodeWrapper(kind string, data io.Reader, decodeBody bodyDecoder) (actual *model.UpdateWrapper, err error) {
 	actual = &model.UpdateWrapper{}
 	switch kind {
 	case "update_dependency_list":
-		actual.Data, err = decode[model.UpdateDependencyList](data)
+		actual.Data, err = decode[model.UpdateDependencyList](data, decodeBody)
 	case "create_pull_request":
 		var createPR model.CreatePullRequest
-		createPR, err = decode[model.CreatePullRequest](data)
-		createPR.UpdatedDependencyFiles = replaceBinaryWithHash(createPR.UpdatedDependencyFiles)
+		createPR, err = decode[model.CreatePullRequest](data, decodeBody)
+		if err == nil {
+			createPR.UpdatedDependencyFiles, err = replaceBinaryWithHash(createPR.UpdatedDependencyFiles)
+		}
 		actual.Data = createPR
 	case "update_pull_request":
 		var updatePR model.UpdatePullRequest
-		updatePR, err = decode[model.UpdatePullRequest](data)
-		updatePR.UpdatedDependencyFiles = replaceBinaryWithHash(updatePR.UpdatedDependencyFiles)
+		updatePR, err = decode[model.UpdatePullRequest](data, decodeBody)
+		if err == nil {
+			updatePR.UpdatedDependencyFiles, err = replaceBinaryWithHash(updatePR.UpdatedDependencyFiles)
+		}
 		actual.Data = updatePR
 	case "close_pull_request":
-		actual.Data, err = decode[model.ClosePullRequest](data)
+		actual.Data, err = decode[model.ClosePullRequest](data, decodeBody)
 	case "mark_as_processed":
-		actual.Data, err = decode[model.MarkAsProcessed](data)
+		actual.Data, err = decode[model.MarkAsProcessed](data, decodeBody)
 	case "record_ecosystem_versions":
-		actual.Data, err = decode[model.RecordEcosystemVersions](data)
+		actual.Data, err = decode[model.RecordEcosystemVersions](data, decodeBody)
 	case "record_update_job_error":
-		actual.Data, err = decode[model.RecordUpdateJobError](data)
+		actual.Data, err = decode[model.RecordUpdateJobError](data, decodeBody)
 	case "record_update_job_unknown_error":
-		actual.Data, err = decode[model.RecordUpdateJobUnknownError](data)
+		actual.Data, err = decode[model.RecordUpdateJobUnknownError](data, decodeBody)
+	case "record_update_job_warning":
+		actual.Data, err = decode[model.RecordUpdateJobWarning](data, decodeBody)
 	case "increment_metric":
-		actual.Data, err = decode[model.IncrementMetric](data)
+		actual.Data, err = decode[model.IncrementMetric](data, decodeBody)
 	default:
-		return nil, fmt.Errorf("unexpected output type: %s", kind)
+		decodeRegistered, ok := registeredDecoder(kind)
+		if !ok {
+			return nil, fmt.Errorf("unexpected output type: %s", kind)
+		}
+		actual.Data, err = decodeRegistered(data, decodeBody)
 	}
 	return actual, err
 }
 
-// to avoid having massive base64 encoded strings in the test fixtures, replace the content with a hash
-func replaceBinaryWithHash(files []model.DependencyFile) []model.DependencyFile {
+// to avoid having massive base64 encoded strings in the test fixtures, replace the content with a hash.
+// The decoded content is kept in BinaryContent so files can still be compared byte for byte.
+func replaceBinaryWithHash(files []model.DependencyFile) ([]model.DependencyFile, error) {
 	for i := range files {
 		file := &files[i]
 		if file.ContentEncoding == "base64" {
+			content, err := base64.StdEncoding.DecodeString(file.Content)
+			if err != nil {
+				return files, fmt.Errorf("content of %s isn't valid base64: %w", path.Join(file.Directory, file.Name), err)
+			}
+			file.BinaryContent = content
 			// since this is also called for the expected value, this needs to not be base64
 			// otherwise it will calculate the checksum of the checksum
 			file.ContentEncoding = "sha256"
-			hash := sha256.Sum256([]byte(file.Content))
-			file.Content = hex.EncodeToString(hash[:])
+			file.Content = contentHash(file.Content)
+		}
+	}
+	return files, nil
+}
+
+// largeFileSize is the size of content over which a recorded file keeps its content_hash instead
+const largeFileSize = 64 << 10
+
+// hashLargeFiles returns a copy of files with content over largeFileSize replaced by a content_hash,
+// so recordings of updates to large files such as lockfiles are quick to compare and don't balloon
+func hashLargeFiles(files []model.DependencyFile) []model.DependencyFile {
+	if !slices.ContainsFunc(files, func(file model.DependencyFile) bool { return len(file.Content) > largeFileSize }) {
+		return files
+	}
+	files = slices.Clone(files)
+	for i := range files {
+		if len(files[i].Content) > largeFileSize {
+			files[i].Content, files[i].ContentHash = "", contentHash(files[i].Content)
 		}
 	}
 	return files
 }
 
-func decode[T any](data []byte) (T, error) {
+// compareBinaryFiles lets binary files match when their content decodes to the same bytes, whatever the hash of
+// the base64 is, e.g. when it's wrapped differently. It returns copies without BinaryContent to compare the rest with.
+func compareBinaryFiles(kind string, expect, actual []model.DependencyFile) ([]model.DependencyFile, []model.DependencyFile, error) {
+	expect, actual = slices.Clone(expect), slices.Clone(actual)
+	actualByPath := make(map[string]*model.DependencyFile, len(actual))
+	for i := range actual {
+		actualByPath[path.Join(actual[i].Directory, actual[i].Name)] = &actual[i]
+	}
+	for i := range expect {
+		e := &expect[i]
+		a, ok := actualByPath[path.Join(e.Directory, e.Name)]
+		if ok && e.BinaryContent != nil && a.BinaryContent != nil {
+			if !bytes.Equal(e.BinaryContent, a.BinaryContent) {
+				return nil, nil, fmt.Errorf("unexpected body for %s:\nbinary content of %s: expected %d bytes got %d bytes that differ",
+					kind, path.Join(e.Directory, e.Name), len(e.BinaryContent), len(a.BinaryContent))
+			}
+			e.Content = a.Content
+		}
+		e.BinaryContent = nil
+	}
+	for i := range actual {
+		actual[i].BinaryContent = nil
+	}
+	return expect, actual, nil
+}
+
+// compareContentHashes checks the content of the files whose expectation has a content_hash against the hash,
+// so a large file isn't compared character by character. Those expected files are changed to match so the
+// rest of their fields can be compared, expect must be a copy like the one compareBinaryFiles returns.
+func compareContentHashes(kind string, expect, actual []model.DependencyFile) error {
+	actualByPath := make(map[string]*model.DependencyFile, len(actual))
+	for i := range actual {
+		actualByPath[path.Join(actual[i].Directory, actual[i].Name)] = &actual[i]
+	}
+	for i := range expect {
+		e := &expect[i]
+		a, ok := actualByPath[path.Join(e.Directory, e.Name)]
+		if !ok || e.ContentHash == "" || a.ContentHash != "" {
+			continue
+		}
+		if hash := contentHash(a.Content); hash != e.ContentHash {
+			return fmt.Errorf("unexpected body for %s:\ncontent_hash of %s: expected %s got %s",
+				kind, path.Join(e.Directory, e.Name), e.ContentHash, hash)
+		}
+		e.Content, e.ContentHash = a.Content, ""
+	}
+	return nil
+}
+
+// contentHash is the hex SHA-256 of content, the form of a content_hash
+func contentHash(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:])
+}
+
+func decode[T any](data io.Reader, decodeBody bodyDecoder) (T, error) {
 	var wrapper struct {
 		Data T `json:"data" yaml:"data"`
 	}
-	decoder := yaml.NewDecoder(bytes.NewBuffer(data))
-	decoder.KnownFields(true)
-	err := decoder.Decode(&wrapper)
+	err := decodeBody(data, &wrapper)
 	if err != nil {
 		return *new(T), err
 	}
@@ -289,67 +1146,239 @@ func compare(expect, actual *model.UpdateWrapper) error {
 		return compareRecordUpdateJobError(v, actual.Data.(model.RecordUpdateJobError))
 	case model.RecordUpdateJobUnknownError:
 		return compareRecordUpdateJobUnknownError(v, actual.Data.(model.RecordUpdateJobUnknownError))
+	case model.RecordUpdateJobWarning:
+		return compareRecordUpdateJobWarning(v, actual.Data.(model.RecordUpdateJobWarning))
+	case model.IncrementMetric:
+		return compareIncrementMetric(v, actual.Data.(model.IncrementMetric))
 	default:
+		if compareRegistered, ok := registeredComparer(v); ok {
+			return compareRegistered(v, actual.Data)
+		}
 		return fmt.Errorf("unexpected type: %s", reflect.TypeOf(v))
 	}
 }
 
-func unexpectedBody(kind string) error {
-	return fmt.Errorf("unexpected body for %s", kind)
+func unexpectedBody(kind string, expect, actual any) error {
+	diffs := fieldDiffs(expect, actual)
+	if len(diffs) == 0 {
+		return fmt.Errorf("unexpected body for %s", kind)
+	}
+	return fmt.Errorf("unexpected body for %s:\n%s", kind, strings.Join(diffs, "\n"))
 }
 
 func compareUpdateDependencyList(expect, actual model.UpdateDependencyList) error {
+	// the updater doesn't guarantee the order of the dependencies
+	expect.Dependencies = sortDependencies(expect.Dependencies)
+	actual.Dependencies = sortDependencies(actual.Dependencies)
 	if reflect.DeepEqual(expect, actual) {
 		return nil
 	}
-	return unexpectedBody("update_dependency_list")
+	err := unexpectedBody("update_dependency_list", expect, actual)
+	byEcosystem := DiffDependencyListsByEcosystem(expect, actual)
+	if diff, ok := byEcosystem[""]; ok && len(byEcosystem) == 1 {
+		// without ecosystems there's nothing to group by
+		return fmt.Errorf("%w\nsummary:\n%s", err, diff)
+	}
+	for _, ecosystem := range sortedKeys(byEcosystem) {
+		name := ecosystem
+		if name == "" {
+			name = "(no ecosystem)"
+		}
+		err = fmt.Errorf("%w\n%s dependencies differ:\n%s", err, name, byEcosystem[ecosystem])
+	}
+	return err
+}
+
+// sortDependencies returns a copy of deps sorted by name and version
+func sortDependencies(deps []model.Dependency) []model.Dependency {
+	if deps == nil {
+		return nil
+	}
+	sorted := slices.Clone(deps)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return dependencyKey(sorted[i]) < dependencyKey(sorted[j])
+	})
+	return sorted
+}
+
+func dependencyKey(dep model.Dependency) string {
+	if dep.Version == nil {
+		return dep.Name
+	}
+	return dep.Name + "@" + *dep.Version
 }
 
 func compareCreatePullRequest(expect, actual model.CreatePullRequest) error {
+	if expect.PRTitlePattern != "" {
+		pattern, err := regexp.Compile(expect.PRTitlePattern)
+		if err != nil {
+			return fmt.Errorf("invalid pr-title-pattern: %w", err)
+		}
+		if !pattern.MatchString(actual.PRTitle) {
+			return fmt.Errorf("unexpected body for create_pull_request:\npr-title: expected to match %q got %q", expect.PRTitlePattern, actual.PRTitle)
+		}
+		// the pattern matched, so compare the rest as usual
+		expect.PRTitle, expect.PRTitlePattern = actual.PRTitle, ""
+	}
+	if expect.PRBodyPattern != "" {
+		pattern, err := regexp.Compile(expect.PRBodyPattern)
+		if err != nil {
+			return fmt.Errorf("invalid pr-body-pattern: %w", err)
+		}
+		if !pattern.MatchString(actual.PRBody) {
+			offset := mismatchOffset(expect.PRBodyPattern, actual.PRBody)
+			return fmt.Errorf("unexpected body for create_pull_request:\npr-body: expected to match %q, stopped matching at offset %d: %q\ngot %q",
+				expect.PRBodyPattern, offset, excerpt(actual.PRBody[offset:], 40), actual.PRBody)
+		}
+		expect.PRBody, expect.PRBodyPattern = actual.PRBody, ""
+	}
+	// labels aren't ordered, so compare them as sets
+	if diff := diffLabels(expect.Labels, actual.Labels); diff != "" {
+		return fmt.Errorf("unexpected labels for create_pull_request:\n%s", diff)
+	}
+	expect.Labels = actual.Labels
+	// neither are reviewers
+	if err := compareReviewers(expect.Reviewers, actual.Reviewers); err != nil {
+		return err
+	}
+	expect.Reviewers = actual.Reviewers
+	var err error
+	expect.UpdatedDependencyFiles, actual.UpdatedDependencyFiles, err = compareBinaryFiles(
+		"create_pull_request", expect.UpdatedDependencyFiles, actual.UpdatedDependencyFiles)
+	if err != nil {
+		return err
+	}
+	if err = compareContentHashes("create_pull_request", expect.UpdatedDependencyFiles, actual.UpdatedDependencyFiles); err != nil {
+		return err
+	}
 	if reflect.DeepEqual(expect, actual) {
 		return nil
 	}
-	return unexpectedBody("create_pull_request")
+	return unexpectedBody("create_pull_request", expect, actual)
+}
+
+// diffLabels returns a line for each label that was added (+) or is missing (-), or an empty string if they're the same set
+func diffLabels(expect, actual []string) string {
+	expect, actual = sortedSet(expect), sortedSet(actual)
+	var lines []string
+	for _, label := range missingFrom(actual, expect) {
+		lines = append(lines, "+ "+label)
+	}
+	for _, label := range missingFrom(expect, actual) {
+		lines = append(lines, "- "+label)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func compareReviewers(expect, actual []string) error {
+	expect, actual = sortedSet(expect), sortedSet(actual)
+	if slices.Equal(expect, actual) {
+		return nil
+	}
+	msg := fmt.Sprintf("unexpected reviewers for create_pull_request:\nexpected reviewers: [%s], got: [%s]",
+		strings.Join(expect, ", "), strings.Join(actual, ", "))
+	if missing := missingFrom(expect, actual); len(missing) > 0 {
+		msg += "\nmissing reviewers: " + strings.Join(missing, ", ")
+	}
+	if unexpected := missingFrom(actual, expect); len(unexpected) > 0 {
+		msg += "\nunexpected reviewers: " + strings.Join(unexpected, ", ")
+	}
+	return errors.New(msg)
+}
+
+// sortedSet returns a sorted copy of values without duplicates
+func sortedSet(values []string) []string {
+	values = slices.Clone(values)
+	slices.Sort(values)
+	return slices.Compact(values)
 }
 
 func compareUpdatePullRequest(expect, actual model.UpdatePullRequest) error {
+	if onlyPRNumber(expect) {
+		if expect.PRNumber != actual.PRNumber {
+			return fmt.Errorf("unexpected body for update_pull_request:\npr-number: expected %d got %d", expect.PRNumber, actual.PRNumber)
+		}
+		return nil
+	}
+	var err error
+	expect.UpdatedDependencyFiles, actual.UpdatedDependencyFiles, err = compareBinaryFiles(
+		"update_pull_request", expect.UpdatedDependencyFiles, actual.UpdatedDependencyFiles)
+	if err != nil {
+		return err
+	}
+	if err = compareContentHashes("update_pull_request", expect.UpdatedDependencyFiles, actual.UpdatedDependencyFiles); err != nil {
+		return err
+	}
 	if reflect.DeepEqual(expect, actual) {
 		return nil
 	}
-	return unexpectedBody("update_pull_request")
+	err = unexpectedBody("update_pull_request", expect, actual)
+	if summary := DiffUpdatePullRequest(expect, actual); summary != "" {
+		err = fmt.Errorf("%w\nsummary:\n%s", err, summary)
+	}
+	return err
+}
+
+// onlyPRNumber is true for an expectation that only says which pull request is updated
+func onlyPRNumber(expect model.UpdatePullRequest) bool {
+	number := expect.PRNumber
+	expect.PRNumber = 0
+	return number != 0 && reflect.ValueOf(expect).IsZero()
 }
 
 func compareClosePullRequest(expect, actual model.ClosePullRequest) error {
+	// a typo in the expected reason would otherwise look like the updater misbehaving,
+	// an empty one is a null, ignored or partial expectation that accepts any reason
+	if expect.Reason != "" && !model.ValidClosePRReasons[expect.Reason] {
+		return fmt.Errorf("unknown close reason %q in the expectation for close_pull_request", expect.Reason)
+	}
 	if reflect.DeepEqual(expect, actual) {
 		return nil
 	}
-	return unexpectedBody("close_pull_request")
+	return unexpectedBody("close_pull_request", expect, actual)
 }
 
 func compareRecordEcosystemVersions(expect, actual model.RecordEcosystemVersions) error {
+	if err := model.ValidateEcosystemVersions(actual); err != nil {
+		return fmt.Errorf("invalid record_ecosystem_versions: %w", err)
+	}
 	if reflect.DeepEqual(expect, actual) {
 		return nil
 	}
-	return unexpectedBody("record_ecosystem_versions")
+	return unexpectedBody("record_ecosystem_versions", expect, actual)
 }
 
 func compareMarkAsProcessed(expect, actual model.MarkAsProcessed) error {
 	if reflect.DeepEqual(expect, actual) {
 		return nil
 	}
-	return unexpectedBody("mark_as_processed")
+	return unexpectedBody("mark_as_processed", expect, actual)
 }
 
 func compareRecordUpdateJobError(expect, actual model.RecordUpdateJobError) error {
 	if reflect.DeepEqual(expect, actual) {
 		return nil
 	}
-	return unexpectedBody("record_update_job_error")
+	return unexpectedBody("record_update_job_error", expect, actual)
 }
 
 func compareRecordUpdateJobUnknownError(expect, actual model.RecordUpdateJobUnknownError) error {
 	if reflect.DeepEqual(expect, actual) {
 		return nil
 	}
-	return unexpectedBody("record_update_job_unknown_error")
+	return unexpectedBody("record_update_job_unknown_error", expect, actual)
+}
+
+func compareRecordUpdateJobWarning(expect, actual model.RecordUpdateJobWarning) error {
+	if reflect.DeepEqual(expect, actual) {
+		return nil
+	}
+	return unexpectedBody("record_update_job_warning", expect, actual)
+}
+
+func compareIncrementMetric(expect, actual model.IncrementMetric) error {
+	if reflect.DeepEqual(expect, actual) {
+		return nil
+	}
+	return unexpectedBody("increment_metric", expect, actual)
 }
@@ -1,18 +1,131 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/dependabot/cli/internal/model"
+	"gopkg.in/yaml.v3"
 )
 
 func Test_decodeWrapper(t *testing.T) {
 	t.Run("reject extra data", func(t *testing.T) {
-		_, err := decodeWrapper("update_dependency_list", []byte(`data: {"unknown": "value"}`))
+		_, err := decodeWrapper("update_dependency_list", strings.NewReader(`data: {"unknown": "value"}`), decodeYAML)
 		if err == nil {
 			t.Error("expected decode would error on extra data")
 		}
+		_, err = decodeWrapper("update_dependency_list", strings.NewReader(`{"data": {"unknown": "value"}}`), decodeJSON)
+		if err == nil {
+			t.Error("expected JSON decode would error on extra data")
+		}
 	})
+
+	t.Run("JSON and YAML decode the same", func(t *testing.T) {
+		payload := `{"data": {"error-type": "unknown", "error-details": {"count": 2, "ratio": 0.5, "items": [1, {"n": 3}]}}}`
+		fromJSON, err := decodeWrapper("record_update_job_error", strings.NewReader(payload), decodeJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromYAML, err := decodeWrapper("record_update_job_error", strings.NewReader(payload), decodeYAML)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(fromJSON, fromYAML) {
+			t.Errorf("expected the same payload, got %#v from JSON and %#v from YAML", fromJSON.Data, fromYAML.Data)
+		}
+	})
+
+	t.Run("picks the decoder by Content-Type", func(t *testing.T) {
+		for contentType, want := range map[string]bodyDecoder{
+			"application/json":                decodeJSON,
+			"application/json; charset=utf-8": decodeJSON,
+			"application/vnd.api+json":        decodeJSON,
+			"application/yaml":                decodeYAML,
+			"application/x-yaml":              decodeYAML,
+			"":                                decodeYAML,
+		} {
+			if got := bodyDecoderFor(contentType); reflect.ValueOf(got).Pointer() != reflect.ValueOf(want).Pointer() {
+				t.Errorf("wrong decoder for %q", contentType)
+			}
+		}
+	})
+}
+
+// largeDependencyList is an update_dependency_list of roughly 10 MB, the size of a large dependency graph
+func largeDependencyList() string {
+	var payload strings.Builder
+	payload.WriteString(`{"data":{"dependency_files":["/go.mod"],"dependencies":[`)
+	for i := 0; payload.Len() < 10<<20; i++ {
+		if i > 0 {
+			payload.WriteString(",")
+		}
+		fmt.Fprintf(&payload, `{"name":"github.com/example/dep%d","version":"1.0.%d","requirements":[{"file":"go.mod","groups":[],"requirement":"v1.0.%d","source":null}]}`, i, i, i)
+	}
+	payload.WriteString("]}}")
+	return payload.String()
+}
+
+func Benchmark_decodeWrapper(b *testing.B) {
+	data := largeDependencyList()
+
+	// the JSON the updater sends, decoded straight from the body as serve does
+	b.Run("streaming", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			if _, err := decodeWrapper("update_dependency_list", strings.NewReader(data), decodeJSON); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	// reading the whole body first, as serve used to
+	b.Run("buffered", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			body, err := io.ReadAll(strings.NewReader(data))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := decodeWrapper("update_dependency_list", bytes.NewReader(body), decodeJSON); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkAPI_ServeHTTP(b *testing.B) {
+	data := largeDependencyList()
+	api := NewAPI(nil, nil)
+	defer api.Stop()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		request := httptest.NewRequest("POST", "/update_jobs/1/update_dependency_list", strings.NewReader(data))
+		request.Header.Set("Content-Type", "application/json")
+		response := httptest.NewRecorder()
+		api.ServeHTTP(response, request)
+		if response.Code != http.StatusOK {
+			b.Fatalf("expected a 200, got %d: %s", response.Code, response.Body)
+		}
+		// so the calls recorded don't add up across iterations
+		api.Reset(nil)
+	}
 }
 
 func TestAPI_ServeHTTP(t *testing.T) {
@@ -27,4 +140,1699 @@ func TestAPI_ServeHTTP(t *testing.T) {
 			t.Errorf("expected status code %d, got %d", http.StatusNotImplemented, response.Code)
 		}
 	})
+
+	t.Run("returns 422 when the body can't be decoded", func(t *testing.T) {
+		request := httptest.NewRequest("POST", "/update_jobs/1/update_dependency_list", strings.NewReader(`{"data":{"unknown":"value"}}`))
+		response := httptest.NewRecorder()
+
+		api := NewAPI(nil, nil)
+		defer api.Stop()
+		api.ServeHTTP(response, request)
+
+		if response.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, got %d", http.StatusUnprocessableEntity, response.Code)
+		}
+		var body map[string]string
+		if err := json.NewDecoder(response.Body).Decode(&body); err != nil || body["error"] == "" {
+			t.Errorf("expected a JSON error body, got %q", response.Body.String())
+		}
+		if len(api.Errors) != 1 {
+			t.Errorf("expected the error to be recorded, got %v", api.Errors)
+		}
+		if len(api.Actual.Output) != 0 {
+			t.Errorf("expected the invalid payload to not be recorded, got %v", api.Actual.Output)
+		}
+	})
+}
+
+func Test_compareCreatePullRequest(t *testing.T) {
+	t.Run("reports the path of each differing field", func(t *testing.T) {
+		expectedVersion, actualVersion := "1.2.0", "1.3.0"
+		expect := model.CreatePullRequest{
+			PRTitle:      "Bump foo",
+			Dependencies: []model.Dependency{{Name: "foo", Version: &expectedVersion}},
+		}
+		actual := model.CreatePullRequest{
+			PRTitle:      "Bump foo",
+			Dependencies: []model.Dependency{{Name: "foo", Version: &actualVersion}},
+		}
+		err := compareCreatePullRequest(expect, actual)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		want := `dependencies[0].version: expected "1.2.0" got "1.3.0"`
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %q", want, err.Error())
+		}
+		if strings.Contains(err.Error(), "pr-title") {
+			t.Errorf("expected equal fields to be omitted, got %q", err.Error())
+		}
+	})
+}
+
+func TestAPI_RecordUpdateJobWarning(t *testing.T) {
+	body := `{"data":{"warn-type":"deprecation","warn-title":"Deprecated","warn-description":"Use something else"}}`
+
+	t.Run("records the warning", func(t *testing.T) {
+		api := NewAPI(nil, nil)
+		defer api.Stop()
+
+		request := httptest.NewRequest("POST", "/update_jobs/1/record_update_job_warning", strings.NewReader(body))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+
+		if len(api.Errors) != 0 {
+			t.Fatalf("expected no errors, got %v", api.Errors)
+		}
+		if len(api.Actual.Output) != 1 || api.Actual.Output[0].Type != "record_update_job_warning" {
+			t.Fatalf("expected the warning to be recorded, got %v", api.Actual.Output)
+		}
+	})
+
+	t.Run("unmatched warning expectations are reported", func(t *testing.T) {
+		api := NewAPI([]model.Output{{
+			Type: "record_update_job_warning",
+			Expect: model.UpdateWrapper{Data: model.RecordUpdateJobWarning{
+				WarnType: "deprecation",
+			}},
+		}}, nil)
+		defer api.Stop()
+
+		api.Complete()
+
+		if len(api.Errors) != 1 {
+			t.Errorf("expected an unmet expectation error, got %v", api.Errors)
+		}
+	})
+}
+
+func TestAPI_Complete(t *testing.T) {
+	t.Run("records the actual scenario", func(t *testing.T) {
+		api := NewAPI(nil, nil)
+		defer api.Stop()
+		api.RecordPath = filepath.Join(t.TempDir(), "scenario.yml")
+
+		body := `{"data":{"base-commit-sha":"1234"}}`
+		request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(body))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+		api.Complete()
+
+		data, err := os.ReadFile(api.RecordPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var scenario model.Scenario
+		if err = yaml.Unmarshal(data, &scenario); err != nil {
+			t.Fatal(err)
+		}
+		if scenario.Input.Job.Source.Commit != "1234" {
+			t.Errorf("expected the commit to be recorded, got %q", scenario.Input.Job.Source.Commit)
+		}
+		if len(scenario.Output) != 1 || scenario.Output[0].Type != "mark_as_processed" {
+			t.Errorf("expected the output to be recorded, got %v", scenario.Output)
+		}
+	})
+}
+
+// fatalRecorder records the call to Fatalf instead of failing the test
+type fatalRecorder struct {
+	fatal string
+}
+
+func (f *fatalRecorder) Helper() {}
+
+func (f *fatalRecorder) Fatalf(format string, args ...any) {
+	f.fatal = fmt.Sprintf(format, args...)
+}
+
+func TestAPI_MustComplete(t *testing.T) {
+	expected := []model.Output{
+		{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}}},
+	}
+	api := NewAPI(expected, nil)
+	defer api.Stop()
+
+	tb := &fatalRecorder{}
+	api.MustComplete(tb)
+	if !strings.HasPrefix(tb.fatal, "expectation not met: mark_as_processed") {
+		t.Errorf("expected the unmet expectation to be fatal, got %q", tb.fatal)
+	}
+
+	api.Reset(expected)
+	if err := api.InjectRequest("mark_as_processed", []byte(`{"data":{"base-commit-sha":"1234"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	api.MustComplete(t)
+}
+
+func TestNewAPI(t *testing.T) {
+	t.Run("reports its address and URL", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		api := NewAPI(nil, nil, WithListener(l))
+		defer api.Stop()
+
+		if api.Addr() != l.Addr().String() {
+			t.Errorf("expected address %s, got %s", l.Addr(), api.Addr())
+		}
+		if want := fmt.Sprintf("http://127.0.0.1:%d", api.Port()); api.URL() != want {
+			t.Errorf("expected URL %s, got %s", want, api.URL())
+		}
+	})
+
+	t.Run("serves on the provided listener", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		api := NewAPI(nil, nil, WithListener(l))
+		defer api.Stop()
+
+		if api.Port() != l.Addr().(*net.TCPAddr).Port {
+			t.Errorf("expected port %d, got %d", l.Addr().(*net.TCPAddr).Port, api.Port())
+		}
+		resp, err := http.Post("http://"+l.Addr().String()+"/update_jobs/1/mark_as_processed", "application/json", strings.NewReader(`{"data":{"base-commit-sha":"1234"}}`))
+		if err != nil {
+			t.Fatalf("expected the request to succeed: %v", err)
+		}
+		resp.Body.Close()
+	})
+
+	t.Run("serves on a Unix socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "api.sock")
+		api := NewAPI(nil, nil, WithUnixSocket(path))
+		defer api.Stop()
+
+		if api.Port() != -1 {
+			t.Errorf("expected port -1, got %d", api.Port())
+		}
+		if api.SocketPath() != path {
+			t.Errorf("expected socket path %s, got %s", path, api.SocketPath())
+		}
+		if api.Addr() != "" {
+			t.Errorf("expected no host:port address, got %s", api.Addr())
+		}
+		if api.URL() != "http://unix" {
+			t.Errorf("expected the placeholder URL http://unix, got %s", api.URL())
+		}
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", api.SocketPath())
+			},
+		}}
+		resp, err := client.Post(api.URL()+"/update_jobs/1/mark_as_processed", "application/json", strings.NewReader(`{"data":{"base-commit-sha":"1234"}}`))
+		if err != nil {
+			t.Fatalf("expected the request to succeed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected a 200, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("listens on the IPv6 loopback", func(t *testing.T) {
+		t.Setenv("FAKE_API_HOST_IPV6", "1")
+		api := NewAPI(nil, nil)
+		defer api.Stop()
+
+		url := fmt.Sprintf("http://[::1]:%d/update_jobs/1/mark_as_processed", api.Port())
+		resp, err := http.Post(url, "application/json", strings.NewReader(`{"data":{"base-commit-sha":"1234"}}`))
+		if err != nil {
+			t.Fatalf("expected the request to succeed: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+	})
+
+	t.Run("options override the default timeouts", func(t *testing.T) {
+		api := NewAPI(nil, nil,
+			WithReadTimeout(time.Minute),
+			WithWriteTimeout(2*time.Minute),
+			WithIdleTimeout(3*time.Minute),
+		)
+		defer api.Stop()
+
+		if api.server.ReadTimeout != time.Minute {
+			t.Errorf("expected read timeout to be set, got %v", api.server.ReadTimeout)
+		}
+		if api.server.WriteTimeout != 2*time.Minute {
+			t.Errorf("expected write timeout to be set, got %v", api.server.WriteTimeout)
+		}
+		if api.server.IdleTimeout != 3*time.Minute {
+			t.Errorf("expected idle timeout to be set, got %v", api.server.IdleTimeout)
+		}
+	})
+}
+
+func TestAPI_assertExpectation(t *testing.T) {
+	closePR := func(reason string) model.Output {
+		return model.Output{
+			Type:      "close_pull_request",
+			Expect:    model.UpdateWrapper{Data: model.ClosePullRequest{Reason: reason}},
+			Unordered: true,
+		}
+	}
+	send := func(api *API, kind, body string) {
+		request := httptest.NewRequest("POST", "/update_jobs/1/"+kind, strings.NewReader(body))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	t.Run("unordered expectations match in any order", func(t *testing.T) {
+		api := NewAPI([]model.Output{
+			closePR("up_to_date"),
+			closePR("dependency_removed"),
+			{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}}},
+		}, nil)
+		defer api.Stop()
+
+		send(api, "close_pull_request", `{"data":{"dependency-names":null,"reason":"dependency_removed"}}`)
+		send(api, "close_pull_request", `{"data":{"dependency-names":null,"reason":"up_to_date"}}`)
+		send(api, "mark_as_processed", `{"data":{"base-commit-sha":"1234"}}`)
+		api.Complete()
+
+		if len(api.Errors) != 0 {
+			t.Errorf("expected no errors, got %v", api.Errors)
+		}
+	})
+
+	t.Run("every failed expectation is reported", func(t *testing.T) {
+		markAsProcessed := func(sha string) model.Output {
+			return model.Output{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: sha}}}
+		}
+		api := NewAPI([]model.Output{markAsProcessed("1"), markAsProcessed("2"), markAsProcessed("3"), markAsProcessed("4")}, nil)
+		defer api.Stop()
+
+		for _, sha := range []string{"1", "x", "3", "y"} {
+			send(api, "mark_as_processed", fmt.Sprintf(`{"data":{"base-commit-sha":%q}}`, sha))
+		}
+		api.Complete()
+
+		if len(api.Errors) != 2 {
+			t.Fatalf("expected an error for expectations 2 and 4, got %v", api.Errors)
+		}
+		for i, want := range []string{`expected "2" got "x"`, `expected "4" got "y"`} {
+			if !strings.Contains(api.Errors[i].Error(), want) {
+				t.Errorf("expected error %d to contain %q, got %q", i, want, api.Errors[i])
+			}
+		}
+	})
+
+	t.Run("match predicate replaces the comparison", func(t *testing.T) {
+		api := NewAPI([]model.Output{{
+			Type: "update_pull_request",
+			Match: func(actual *model.UpdateWrapper) bool {
+				_, isClose := actual.Data.(model.ClosePullRequest)
+				_, isUpdate := actual.Data.(model.UpdatePullRequest)
+				return isClose || isUpdate
+			},
+		}}, nil)
+		defer api.Stop()
+
+		send(api, "close_pull_request", `{"data":{"dependency-names":null,"reason":"up_to_date"}}`)
+		api.Complete()
+
+		if len(api.Errors) != 0 {
+			t.Errorf("expected the predicate to match, got %v", api.Errors)
+		}
+	})
+
+	t.Run("after is called once the output is matched", func(t *testing.T) {
+		var called []string
+		after := func(sha string) func() {
+			return func() { called = append(called, sha) }
+		}
+		api := NewAPI([]model.Output{
+			{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1"}}, After: after("1")},
+			{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "2"}}, After: after("2")},
+			{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "3"}}, After: after("3"), Unordered: true},
+		}, nil)
+		defer api.Stop()
+
+		for _, sha := range []string{"1", "x", "3"} {
+			send(api, "mark_as_processed", fmt.Sprintf(`{"data":{"base-commit-sha":%q}}`, sha))
+		}
+
+		if !reflect.DeepEqual(called, []string{"1", "3"}) {
+			t.Errorf("expected After to be called for the matched outputs, got %v", called)
+		}
+	})
+
+	t.Run("partial match only compares the fields that are set", func(t *testing.T) {
+		api := NewAPI([]model.Output{{
+			Type:         "create_pull_request",
+			Expect:       model.UpdateWrapper{Data: model.CreatePullRequest{PRTitle: "Bump foo"}},
+			PartialMatch: true,
+		}}, nil)
+		defer api.Stop()
+
+		send(api, "create_pull_request", `{"data":{"base-commit-sha":"1234","dependencies":[{"name":"foo","requirements":[],"version":"1.0.0"}],"updated-dependency-files":[],"pr-title":"Bump foo","pr-body":"body","commit-message":"message","dependency-group":null}}`)
+
+		if len(api.Errors) != 0 {
+			t.Fatalf("expected the unset fields to match anything, got %v", api.Errors)
+		}
+	})
+
+	t.Run("partial match still compares the fields that are set", func(t *testing.T) {
+		api := NewAPI([]model.Output{{
+			Type:         "create_pull_request",
+			Expect:       model.UpdateWrapper{Data: model.CreatePullRequest{PRTitle: "Bump foo"}},
+			PartialMatch: true,
+		}}, nil)
+		defer api.Stop()
+
+		send(api, "create_pull_request", `{"data":{"base-commit-sha":"1234","dependencies":[],"updated-dependency-files":[],"pr-title":"Bump bar","pr-body":"","commit-message":"","dependency-group":null}}`)
+
+		if len(api.Errors) != 1 || !strings.Contains(api.Errors[0].Error(), "pr-title") {
+			t.Fatalf("expected the title to differ, got %v", api.Errors)
+		}
+	})
+
+	t.Run("partial match checks the title and body patterns against the actual values", func(t *testing.T) {
+		for _, tc := range []struct {
+			name, title, body string
+			wantErr           string
+		}{
+			{"both match", "Bump foo from 1.0.0 to 1.1.0", "Bumps foo.", ""},
+			{"title doesn't match", "Update foo", "Bumps foo.", "pr-title: expected to match"},
+			{"body doesn't match", "Bump foo from 1.0.0 to 1.1.0", "Updates foo.", "pr-body: expected to match"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				api := NewAPI([]model.Output{{
+					Type:         "create_pull_request",
+					Expect:       model.UpdateWrapper{Data: model.CreatePullRequest{PRTitlePattern: "^Bump foo", PRBodyPattern: "^Bumps foo"}},
+					PartialMatch: true,
+				}}, nil)
+				defer api.Stop()
+
+				err := api.InjectRequest("create_pull_request", []byte(fmt.Sprintf(`{"data":{"base-commit-sha":"1234","dependencies":[],"updated-dependency-files":[],"pr-title":%q,"pr-body":%q,"commit-message":"message"}}`, tc.title, tc.body)))
+				if tc.wantErr == "" && err != nil {
+					t.Errorf("expected the patterns to match, got %v", err)
+				}
+				if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+					t.Errorf("expected an error containing %q, got %v", tc.wantErr, err)
+				}
+			})
+		}
+	})
+
+	t.Run("ordered expectation waits for the unordered ones before it", func(t *testing.T) {
+		api := NewAPI([]model.Output{
+			closePR("up_to_date"),
+			{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}}},
+		}, nil)
+		defer api.Stop()
+
+		send(api, "mark_as_processed", `{"data":{"base-commit-sha":"1234"}}`)
+		api.Complete()
+
+		if len(api.Errors) != 3 {
+			t.Errorf("expected the mismatch and both unmet expectations, got %v", api.Errors)
+		}
+	})
+}
+
+func TestAPI_Reset(t *testing.T) {
+	api := NewAPI(nil, nil)
+	defer api.Stop()
+
+	request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(`{"data":{"base-commit-sha":"1234"}}`))
+	api.ServeHTTP(httptest.NewRecorder(), request)
+	api.Errors = append(api.Errors, errors.New("previous failure"))
+	port := api.Port()
+
+	expected := []model.Output{{Type: "mark_as_processed"}}
+	api.Reset(expected)
+
+	if len(api.Errors) != 0 || len(api.Actual.Output) != 0 || api.Actual.Input.Job.Source.Commit != "" {
+		t.Errorf("expected results to be cleared, got errors %v and actual %v", api.Errors, api.Actual)
+	}
+	if len(api.Expectations) != 1 || !api.hasExpectations || api.cursor != 0 {
+		t.Errorf("expected the new expectations to be pending")
+	}
+	if api.Port() != port {
+		t.Errorf("expected the server to keep its port")
+	}
+}
+
+func TestWithIgnoreFields(t *testing.T) {
+	expected := []model.Output{{
+		Type: "create_pull_request",
+		Expect: model.UpdateWrapper{Data: model.CreatePullRequest{
+			PRTitle: "Bump foo",
+			PRBody:  "Generated at 10:00",
+		}},
+	}}
+	body := `{"data":{"base-commit-sha":"","dependencies":null,"updated-dependency-files":null,"pr-title":"Bump foo","pr-body":"Generated at 11:00","commit-message":"","dependency-group":null}}`
+
+	api := NewAPI(expected, nil, WithIgnoreFields("data.pr-body"))
+	defer api.Stop()
+
+	request := httptest.NewRequest("POST", "/update_jobs/1/create_pull_request", strings.NewReader(body))
+	api.ServeHTTP(httptest.NewRecorder(), request)
+
+	if len(api.Errors) != 0 {
+		t.Errorf("expected the ignored field to not be compared, got %v", api.Errors)
+	}
+	recorded := api.Actual.Output[0].Expect.Data.(model.CreatePullRequest)
+	if recorded.PRBody != "Generated at 11:00" {
+		t.Errorf("expected the recorded output to keep the ignored field, got %q", recorded.PRBody)
+	}
+}
+
+func TestAPI_nullExpectations(t *testing.T) {
+	var scenario model.Scenario
+	err := yaml.Unmarshal([]byte(`output:
+  - type: create_pull_request
+    expect:
+      data:
+        base-commit-sha: "1234"
+        dependencies:
+          - name: rsc.io/quote
+            version: null
+        updated-dependency-files: []
+        pr-title: Bump rsc.io/quote
+        commit-message: null
+`), &scenario)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(body string) []error {
+		api := NewAPI(scenario.Output, nil)
+		defer api.Stop()
+		if err := api.InjectRequest("create_pull_request", []byte(body)); err != nil {
+			return []error{err}
+		}
+		return nil
+	}
+
+	if errs := send(`{"data":{"base-commit-sha":"1234","dependencies":[{"name":"rsc.io/quote","version":null,"requirements":null}],"updated-dependency-files":[],"pr-title":"Bump rsc.io/quote","commit-message":"Generated on Tuesday"}}`); len(errs) > 0 {
+		t.Errorf("expected a null commit message to match any value, got %v", errs)
+	}
+	if errs := send(`{"data":{"base-commit-sha":"1234","dependencies":[{"name":"rsc.io/quote","version":"1.5.2","requirements":null}],"updated-dependency-files":[],"pr-title":"Bump rsc.io/quote","commit-message":""}}`); len(errs) == 0 {
+		t.Error("expected a null version to still mean no version since it's a pointer")
+	}
+}
+
+func TestAPI_ScenarioFixtures(t *testing.T) {
+	for _, name := range []string{"ecosystem-versions.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("../../testdata/go", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var scenario model.Scenario
+			if err = yaml.Unmarshal(data, &scenario); err != nil {
+				t.Fatal(err)
+			}
+
+			api := NewAPI(scenario.Output, nil)
+			defer api.Stop()
+
+			// play the expected outputs back as if the updater sent them
+			for _, output := range scenario.Output {
+				body, err := json.Marshal(output.Expect)
+				if err != nil {
+					t.Fatal(err)
+				}
+				request := httptest.NewRequest("POST", "/update_jobs/1/"+output.Type, bytes.NewReader(body))
+				api.ServeHTTP(httptest.NewRecorder(), request)
+			}
+			api.Complete()
+
+			if len(api.Errors) != 0 {
+				t.Errorf("expected no errors, got %v", api.Errors)
+			}
+		})
+	}
+}
+
+func TestAPI_WaitForCompletion(t *testing.T) {
+	expected := []model.Output{{
+		Type:   "mark_as_processed",
+		Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}},
+	}}
+
+	t.Run("returns once expectations are met", func(t *testing.T) {
+		api := NewAPI(expected, nil)
+		defer api.Stop()
+
+		go func() {
+			request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(`{"data":{"base-commit-sha":"1234"}}`))
+			api.ServeHTTP(httptest.NewRecorder(), request)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := api.WaitForCompletion(ctx); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("times out when expectations are pending", func(t *testing.T) {
+		api := NewAPI(expected, nil)
+		defer api.Stop()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := api.WaitForCompletion(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected a timeout, got %v", err)
+		}
+	})
+}
+
+func TestWithDryRun(t *testing.T) {
+	var out bytes.Buffer
+	expected := []model.Output{{
+		Type:   "mark_as_processed",
+		Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}},
+	}}
+	api := NewAPI(expected, nil, WithDryRun(&out))
+	defer api.Stop()
+
+	request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(`{"data":{"base-commit-sha":"5678"}}`))
+	api.ServeHTTP(httptest.NewRecorder(), request)
+	api.Complete()
+
+	if len(api.Errors) != 0 {
+		t.Errorf("expected expectations to be skipped, got %v", api.Errors)
+	}
+	if !strings.Contains(out.String(), "type: mark_as_processed") || !strings.Contains(out.String(), "base-commit-sha: \"5678\"") {
+		t.Errorf("expected the call to be written as YAML, got %q", out.String())
+	}
+
+	for _, tc := range []struct {
+		name     string
+		expected []model.Output
+	}{
+		{"with expectations", []model.Output{
+			{Type: "create_pull_request", Expect: model.UpdateWrapper{Data: model.CreatePullRequest{PRTitle: "Bump lodash"}}},
+			{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}}},
+		}},
+		{"without expectations", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			api := NewAPI(tc.expected, nil, WithDryRun(io.Discard))
+			defer api.Stop()
+
+			// neither is valid, but a dry run only prints the calls
+			calls := map[string]string{
+				"record_update_job_error": `{"data":{"error-type":"dependency_file_not_fuond","error-details":null}}`,
+				"mark_as_processed":       `{"data":{"base-commit-sha":"not a sha"}}`,
+			}
+			for _, kind := range []string{"record_update_job_error", "mark_as_processed"} {
+				if err := api.InjectRequest(kind, []byte(calls[kind])); err != nil {
+					t.Errorf("expected %s not to be checked, got %v", kind, err)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := api.WaitForCompletion(ctx); err != nil {
+				t.Errorf("expected the run to be complete after mark_as_processed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWithErrorHandler(t *testing.T) {
+	errs := make(chan error, 1)
+	api := NewAPI(nil, nil, WithErrorHandler(func(err error) {
+		errs <- err
+	}))
+	defer api.Stop()
+
+	// closing the listener out from under the server makes it stop unexpectedly
+	if err := api.listener.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("expected an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the error handler to be called")
+	}
+}
+
+func Test_compareCreatePullRequest_PRTitlePattern(t *testing.T) {
+	expect := model.CreatePullRequest{PRTitlePattern: `^Bump lodash from 4\.17\.\d+ to 4\.17\.\d+$`}
+
+	if err := compareCreatePullRequest(expect, model.CreatePullRequest{PRTitle: "Bump lodash from 4.17.20 to 4.17.21"}); err != nil {
+		t.Errorf("expected the title to match, got %v", err)
+	}
+	if err := compareCreatePullRequest(expect, model.CreatePullRequest{PRTitle: "Bump lodash from 4.17.20 to 5.0.0"}); err == nil {
+		t.Error("expected the title to not match")
+	}
+}
+
+func Test_compareCreatePullRequest_PRBodyPattern(t *testing.T) {
+	expect := model.CreatePullRequest{PRBodyPattern: `(?s)^Bumps \[lodash\]\(.*\) from 4\.17\.20 to 4\.17\.\d+\.\n.*Release notes`}
+
+	body := "Bumps [lodash](https://github.com/lodash/lodash) from 4.17.20 to 4.17.21.\n<details>\n<summary>Release notes</summary>"
+	if err := compareCreatePullRequest(expect, model.CreatePullRequest{PRBody: body}); err != nil {
+		t.Errorf("expected the body to match, got %v", err)
+	}
+
+	body = "Bumps [lodash](https://github.com/lodash/lodash) from 4.17.19 to 4.17.21.\n"
+	err := compareCreatePullRequest(expect, model.CreatePullRequest{PRBody: body})
+	want := `pr-body: expected to match "(?s)^Bumps \\[lodash\\]\\(.*\\) from 4\\.17\\.20 to 4\\.17\\.\\d+\\.\\n.*Release notes", ` +
+		`stopped matching at offset 59: "19 to 4.17.21.\n"`
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("expected an error containing:\n%s\ngot:\n%v", want, err)
+	}
+}
+
+func TestAPI_branchPatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []APIOption
+		branch  string
+		wantErr string
+	}{
+		{"any valid branch by default", nil, "deps-npm_and_yarn-lodash-4.17.21", ""},
+		{"invalid Git branch", nil, "dependabot/npm_and_yarn/lodash 4.17.21", "invalid branch-name for create_pull_request"},
+		{"matches an allowed pattern", []APIOption{WithBranchPatterns(model.DefaultBranchPattern)}, "dependabot/npm_and_yarn/lodash-4.17.21", ""},
+		{"doesn't match the allowed patterns", []APIOption{WithBranchPatterns(model.DefaultBranchPattern)}, "lodash-4.17.21",
+			`invalid branch-name for create_pull_request: branch "lodash-4.17.21" doesn't match any of`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// the expectation doesn't set branch-name, it's checked whatever the expectation is
+			api := NewAPI([]model.Output{{
+				Type:         "create_pull_request",
+				Expect:       model.UpdateWrapper{Data: model.CreatePullRequest{PRTitle: "Bump lodash"}},
+				PartialMatch: true,
+			}}, nil, tc.opts...)
+			defer api.Stop()
+
+			err := api.InjectRequest("create_pull_request", []byte(fmt.Sprintf(`{"data":{"base-commit-sha":"1234","dependencies":[],"updated-dependency-files":[],"pr-title":"Bump lodash","branch-name":%q}}`, tc.branch)))
+			if tc.wantErr == "" && err != nil {
+				t.Errorf("expected the branch to be accepted, got %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Errorf("expected an error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func Test_compareCreatePullRequest_Labels(t *testing.T) {
+	expect := model.CreatePullRequest{Labels: []string{"security", "dependencies"}}
+
+	if err := compareCreatePullRequest(expect, model.CreatePullRequest{Labels: []string{"dependencies", "security"}}); err != nil {
+		t.Errorf("expected labels in any order to match, got %v", err)
+	}
+
+	err := compareCreatePullRequest(expect, model.CreatePullRequest{Labels: []string{"dependencies", "go"}})
+	want := "unexpected labels for create_pull_request:\n+ go\n- security"
+	if err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+func Test_compareCreatePullRequest_Reviewers(t *testing.T) {
+	expect := model.CreatePullRequest{Reviewers: []string{"a", "b"}}
+
+	if err := compareCreatePullRequest(expect, model.CreatePullRequest{Reviewers: []string{"b", "a"}}); err != nil {
+		t.Errorf("expected reviewers in any order to match, got %v", err)
+	}
+
+	err := compareCreatePullRequest(expect, model.CreatePullRequest{Reviewers: []string{"c", "b"}})
+	want := "unexpected reviewers for create_pull_request:\nexpected reviewers: [a, b], got: [b, c]\nmissing reviewers: a\nunexpected reviewers: c"
+	if err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+func TestAPI_Metrics(t *testing.T) {
+	api := NewAPI(nil, nil)
+	defer api.Stop()
+
+	for _, metric := range []string{"updater.started", "updater.cache_hit", "updater.cache_hit"} {
+		body := fmt.Sprintf(`{"data":{"metric":%q,"tags":{"package_manager":"go_modules"}}}`, metric)
+		request := httptest.NewRequest("POST", "/update_jobs/1/increment_metric", strings.NewReader(body))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	want := map[string]int{"updater.started": 1, "updater.cache_hit": 2}
+	if got := api.Metrics(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestAPI_ExpectationsMet(t *testing.T) {
+	expected := []model.Output{
+		{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}}},
+		{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "5678"}}},
+	}
+	api := NewAPI(expected, nil)
+	defer api.Stop()
+
+	request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(`{"data":{"base-commit-sha":"1234"}}`))
+	api.ServeHTTP(httptest.NewRecorder(), request)
+
+	if api.ExpectationsMet() {
+		t.Error("expected the second expectation to be unmet")
+	}
+	if unmet := api.UnmetExpectations(); len(unmet) != 1 || unmet[0].Expect.Data.(model.MarkAsProcessed).BaseCommitSha != "5678" {
+		t.Errorf("expected the second expectation to be unmet, got %v", unmet)
+	}
+
+	// errors that aren't about expectations don't count
+	api.Errors = append(api.Errors, errors.New("failed to close body"))
+	request = httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(`{"data":{"base-commit-sha":"5678"}}`))
+	api.ServeHTTP(httptest.NewRecorder(), request)
+
+	if !api.ExpectationsMet() {
+		t.Errorf("expected all expectations to be met, got %v", api.Errors)
+	}
+}
+
+func TestAPI_ErrorCounts(t *testing.T) {
+	expected := []model.Output{
+		{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}}},
+	}
+	api := NewAPI(expected, nil)
+	defer api.Stop()
+
+	for _, body := range []string{`{"data":{"unknown":"value"}}`, `{"data":{"base-commit-sha":"5678"}}`} {
+		request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(body))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	if api.ErrorCount() != 2 || api.ExpectationErrorCount() != 1 || api.DecodeErrorCount() != 1 {
+		t.Errorf("expected 2 errors, 1 expectation and 1 decode, got %d, %d and %d",
+			api.ErrorCount(), api.ExpectationErrorCount(), api.DecodeErrorCount())
+	}
+}
+
+func TestWithActualHook(t *testing.T) {
+	var listed bool
+	var violations []string
+	api := NewAPI(nil, nil, WithActualHook(func(output model.Output) {
+		switch output.Type {
+		case "update_dependency_list":
+			listed = true
+		case "create_pull_request":
+			if !listed {
+				violations = append(violations, "create_pull_request before update_dependency_list")
+			}
+		}
+	}))
+	defer api.Stop()
+
+	for _, kind := range []string{"create_pull_request", "update_dependency_list", "create_pull_request"} {
+		request := httptest.NewRequest("POST", "/update_jobs/1/"+kind, strings.NewReader(`{"data":{}}`))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	if len(violations) != 1 {
+		t.Errorf("expected the hook to see the first create_pull_request before the list, got %v", violations)
+	}
+}
+
+func TestWithStrictOptionals(t *testing.T) {
+	expect := []model.Output{{Type: "create_pull_request", Expect: model.UpdateWrapper{Data: map[string]any{
+		"base-commit-sha":          "1234",
+		"dependencies":             []any{map[string]any{"name": "lodash"}},
+		"updated-dependency-files": []any{},
+		"pr-title":                 "Bump lodash",
+	}}}}
+	withReviewers := `{"data":{"base-commit-sha":"1234","dependencies":[{"name":"lodash"}],"updated-dependency-files":[],` +
+		`"pr-title":"Bump lodash","reviewers":["octocat"],"labels":["dependencies"]}}`
+	withoutDependencies := `{"data":{"base-commit-sha":"1234","dependencies":[],"updated-dependency-files":[],"pr-title":"Bump lodash"}}`
+
+	tests := []struct {
+		name    string
+		opts    []APIOption
+		payload string
+		wantErr bool
+	}{
+		{"strict by default", nil, withReviewers, true},
+		{"unset optionals are ignored", []APIOption{WithStrictOptionals(false)}, withReviewers, false},
+		{"required fields are still compared", []APIOption{WithStrictOptionals(false)}, withoutDependencies, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			api := NewAPI(expect, nil, tc.opts...)
+			defer api.Stop()
+			err := api.InjectRequest("create_pull_request", []byte(tc.payload))
+			if (err != nil) != tc.wantErr {
+				t.Errorf("expected an error: %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	// pr-title is optional, but a pattern for it has to be matched against what was sent
+	expect = []model.Output{{Type: "create_pull_request", Expect: model.UpdateWrapper{Data: map[string]any{
+		"base-commit-sha":          "1234",
+		"dependencies":             []any{map[string]any{"name": "lodash"}},
+		"updated-dependency-files": []any{},
+		"pr-title-pattern":         "^Bump lodash",
+	}}}}
+	tests = []struct {
+		name    string
+		opts    []APIOption
+		payload string
+		wantErr bool
+	}{
+		{"patterns match the actual value", []APIOption{WithStrictOptionals(false)}, withReviewers, false},
+		{"patterns still fail", []APIOption{WithStrictOptionals(false)}, strings.Replace(withReviewers, "Bump lodash", "Update lodash", 1), true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			api := NewAPI(expect, nil, tc.opts...)
+			defer api.Stop()
+			err := api.InjectRequest("create_pull_request", []byte(tc.payload))
+			if (err != nil) != tc.wantErr {
+				t.Errorf("expected an error: %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestWithStrictErrorTypes(t *testing.T) {
+	jobError := func(errorType string) model.Output {
+		return model.Output{Type: "record_update_job_error", Expect: model.UpdateWrapper{Data: model.RecordUpdateJobError{ErrorType: errorType}}}
+	}
+	payload := []byte(`{"data":{"error-type":"dependency_file_not_fuond","error-details":null}}`)
+
+	t.Run("unknown error types are a warning by default", func(t *testing.T) {
+		var out bytes.Buffer
+		api := NewAPI([]model.Output{jobError("dependency_file_not_fuond")}, nil, WithStructuredLogger(slog.New(slog.NewJSONHandler(&out, nil))))
+		defer api.Stop()
+		if err := api.InjectRequest("record_update_job_error", payload); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+		for _, want := range []string{`"source":"expectation"`, `"source":"call"`, `"error_type":"dependency_file_not_fuond"`} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("expected the warning to contain %s, got %s", want, out.String())
+			}
+		}
+	})
+
+	t.Run("unknown error types are an error when strict", func(t *testing.T) {
+		api := NewAPI([]model.Output{jobError("dependency_file_not_found")}, nil, WithStrictErrorTypes())
+		defer api.Stop()
+		err := api.InjectRequest("record_update_job_error", payload)
+		want := `call for record_update_job_error: unknown error type "dependency_file_not_fuond"`
+		if err == nil || err.Error() != want {
+			t.Errorf("expected %q, got %v", want, err)
+		}
+	})
+
+	t.Run("the call is checked without expectations", func(t *testing.T) {
+		api := NewAPI(nil, nil, WithStrictErrorTypes())
+		defer api.Stop()
+		if err := api.InjectRequest("record_update_job_error", payload); err == nil {
+			t.Error("expected an unknown error type error")
+		}
+	})
+}
+
+func TestWithCommitMessageValidation(t *testing.T) {
+	expected := []model.Output{{
+		Type:         "create_pull_request",
+		Expect:       model.UpdateWrapper{Data: model.CreatePullRequest{PRTitle: "Bump lodash"}},
+		PartialMatch: true,
+	}}
+	api := NewAPI(expected, nil, WithCommitMessageValidation(model.CommitMessageConfig{ConventionalCommits: true}))
+	defer api.Stop()
+
+	err := api.InjectRequest("create_pull_request", []byte(`{"data":{"pr-title":"Bump lodash","commit-message":"Bump lodash"}}`))
+	if err == nil || !strings.Contains(err.Error(), "invalid commit message for create_pull_request") {
+		t.Errorf("expected a commit message error, got %v", err)
+	}
+	if api.ExpectationErrorCount() != 1 {
+		t.Errorf("expected the error to count as an expectation error, got %v", api.Errors)
+	}
+}
+
+func TestAPI_malformedBaseCommitSha(t *testing.T) {
+	t.Run("isn't recorded", func(t *testing.T) {
+		api := NewAPI(nil, nil)
+		defer api.Stop()
+
+		err := api.InjectRequest("mark_as_processed", []byte(`{"data":{"base-commit-sha":"not-a-sha"}}`))
+		if err == nil || !strings.Contains(err.Error(), `invalid base-commit-sha for mark_as_processed: "not-a-sha" isn't a Git SHA`) {
+			t.Errorf("expected an invalid SHA error, got %v", err)
+		}
+		if api.Actual.Input.Job.Source.Commit != "" {
+			t.Errorf("expected the commit not to be recorded, got %q", api.Actual.Input.Job.Source.Commit)
+		}
+	})
+
+	t.Run("fails the expectation", func(t *testing.T) {
+		api := NewAPI([]model.Output{
+			{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "not-a-sha"}}},
+		}, nil)
+		defer api.Stop()
+
+		err := api.InjectRequest("mark_as_processed", []byte(`{"data":{"base-commit-sha":"not-a-sha"}}`))
+		if err == nil || api.ExpectationErrorCount() != 1 {
+			t.Errorf("expected an expectation error even though the SHA matches, got %v", err)
+		}
+	})
+
+	sha := "832e37c1a7a4ef89feb9dc7cfa06f62205191994"
+	masked := func(t *testing.T, expect model.Output, opts ...APIOption) *API {
+		t.Helper()
+		api := NewAPI([]model.Output{expect}, nil, opts...)
+		t.Cleanup(api.Stop)
+		return api
+	}
+	var nullSHA model.Scenario
+	if err := yaml.Unmarshal([]byte("output:\n  - type: mark_as_processed\n    expect:\n      data:\n        base-commit-sha: null\n"), &nullSHA); err != nil {
+		t.Fatal(err)
+	}
+	for name, api := range map[string]func(t *testing.T) *API{
+		"null wildcard": func(t *testing.T) *API {
+			return masked(t, nullSHA.Output[0])
+		},
+		"ignored field": func(t *testing.T) *API {
+			return masked(t, model.Output{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{}}},
+				WithIgnoreFields("data.base-commit-sha"))
+		},
+		"partial match": func(t *testing.T) *API {
+			return masked(t, model.Output{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{}}, PartialMatch: true})
+		},
+	} {
+		t.Run(name+" accepts a valid SHA", func(t *testing.T) {
+			if err := api(t).InjectRequest("mark_as_processed", []byte(`{"data":{"base-commit-sha":"`+sha+`"}}`)); err != nil {
+				t.Errorf("expected a masked SHA to match, got %v", err)
+			}
+		})
+		t.Run(name+" still rejects an invalid SHA", func(t *testing.T) {
+			err := api(t).InjectRequest("mark_as_processed", []byte(`{"data":{"base-commit-sha":"not-a-sha"}}`))
+			if err == nil || !strings.Contains(err.Error(), `invalid base-commit-sha for mark_as_processed: "not-a-sha" isn't a Git SHA`) {
+				t.Errorf("expected an invalid SHA error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAPI_ClearErrors(t *testing.T) {
+	expected := []model.Output{
+		{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}}},
+	}
+	api := NewAPI(expected, nil)
+	defer api.Stop()
+
+	for _, body := range []string{`{"data":{"unknown":"value"}}`, `{"data":{"base-commit-sha":"5678"}}`} {
+		request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(body))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	api.ClearExpectationErrors()
+	if api.ErrorCount() != 1 || api.DecodeErrorCount() != 1 {
+		t.Errorf("expected only the decode error to be left, got %v", api.Errors)
+	}
+	api.ClearErrors()
+	if api.ErrorCount() != 0 {
+		t.Errorf("expected no errors, got %v", api.Errors)
+	}
+}
+
+func TestWithStructuredLogger(t *testing.T) {
+	var out bytes.Buffer
+	api := NewAPI(nil, nil, WithStructuredLogger(slog.New(slog.NewJSONHandler(&out, nil))))
+	defer api.Stop()
+
+	request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(`{"data":{"unknown":"value"}}`))
+	api.ServeHTTP(httptest.NewRecorder(), request)
+
+	var entry map[string]any
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a JSON log entry, got %q", out.String())
+	}
+	if entry["level"] != "ERROR" || entry["kind"] != "mark_as_processed" || entry["cursor"] != 0.0 || entry["error"] == nil {
+		t.Errorf("expected the error fields to be logged, got %v", entry)
+	}
+	if len(api.Errors) != 1 {
+		t.Errorf("expected the error to be recorded, got %v", api.Errors)
+	}
+}
+
+func Test_compareUpdatePullRequest_PRNumber(t *testing.T) {
+	actual := model.UpdatePullRequest{
+		BaseCommitSha:   "1234",
+		DependencyNames: []string{"lodash"},
+		PRTitle:         "Bump lodash from 4.17.20 to 4.17.21",
+		PRNumber:        42,
+	}
+
+	if err := compareUpdatePullRequest(model.UpdatePullRequest{PRNumber: 42}, actual); err != nil {
+		t.Errorf("expected only the PR number to be compared, got %v", err)
+	}
+
+	err := compareUpdatePullRequest(model.UpdatePullRequest{PRNumber: 41}, actual)
+	want := "unexpected body for update_pull_request:\npr-number: expected 41 got 42"
+	if err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+
+	// with other fields set it's compared as usual
+	if err = compareUpdatePullRequest(model.UpdatePullRequest{PRNumber: 42, BaseCommitSha: "1234"}, actual); err == nil {
+		t.Error("expected the other fields to be compared")
+	}
+}
+
+func TestDiffUpdatePullRequest(t *testing.T) {
+	expect := model.UpdatePullRequest{
+		DependencyNames:        []string{"rsc.io/quote", "rsc.io/qr"},
+		UpdatedDependencyFiles: []model.DependencyFile{{Directory: "/", Name: "go.mod", Content: "a"}},
+	}
+	actual := model.UpdatePullRequest{
+		DependencyNames:        []string{"rsc.io/quote", "rsc.io/sampler"},
+		UpdatedDependencyFiles: []model.DependencyFile{{Directory: "/", Name: "go.mod", Content: "b"}},
+	}
+
+	want := strings.Join([]string{
+		"dependency rsc.io/qr was not updated",
+		"dependency rsc.io/sampler was unexpectedly updated",
+		"file /go.mod has different changes",
+	}, "\n")
+	if got := DiffUpdatePullRequest(expect, actual); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+	if err := compareUpdatePullRequest(expect, actual); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("expected the summary in the error, got %v", err)
+	}
+}
+
+func Test_compareClosePullRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		expect  string
+		actual  string
+		wantErr string
+	}{
+		{"same reason", "up_to_date", "up_to_date", ""},
+		{"different reason", "up_to_date", "dependency_removed", "unexpected body for close_pull_request"},
+		{"typo in expectation", "uptodate", "up_to_date", `unknown close reason "uptodate" in the expectation for close_pull_request`},
+		{"reason masked on both sides", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := compareClosePullRequest(model.ClosePullRequest{Reason: tt.expect}, model.ClosePullRequest{Reason: tt.actual})
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)) {
+				t.Errorf("expected %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestAPI_closeReason(t *testing.T) {
+	var nullReason model.Scenario
+	if err := yaml.Unmarshal([]byte("output:\n  - type: close_pull_request\n    expect:\n      data:\n        dependency-names: [lodash]\n        reason: null\n"), &nullReason); err != nil {
+		t.Fatal(err)
+	}
+	withoutReason := model.Output{Type: "close_pull_request", Expect: model.UpdateWrapper{Data: model.ClosePullRequest{DependencyNames: []string{"lodash"}}}}
+	partial := withoutReason
+	partial.PartialMatch = true
+	tests := map[string]struct {
+		expect model.Output
+		opts   []APIOption
+	}{
+		"null wildcard": {expect: nullReason.Output[0]},
+		"ignored field": {expect: withoutReason, opts: []APIOption{WithIgnoreFields("data.reason")}},
+		"partial match": {expect: partial},
+	}
+	for name, tt := range tests {
+		send := func(reason string) error {
+			api := NewAPI([]model.Output{tt.expect}, nil, tt.opts...)
+			defer api.Stop()
+			return api.InjectRequest("close_pull_request", []byte(`{"data":{"dependency-names":["lodash"],"reason":"`+reason+`"}}`))
+		}
+		t.Run(name+" accepts any known reason", func(t *testing.T) {
+			if err := send("up_to_date"); err != nil {
+				t.Errorf("expected a masked reason to match, got %v", err)
+			}
+		})
+		t.Run(name+" still rejects an unknown reason", func(t *testing.T) {
+			if err := send("bored"); err == nil || !strings.Contains(err.Error(), `unknown close reason "bored" sent to close_pull_request`) {
+				t.Errorf("expected an unknown reason error, got %v", err)
+			}
+		})
+	}
+}
+
+func Test_compareUpdateDependencyList(t *testing.T) {
+	v1, v2 := "1.0.0", "2.0.0"
+	a := model.Dependency{Name: "a", Version: &v1}
+	b := model.Dependency{Name: "b", Version: &v2}
+
+	t.Run("ignores the order of dependencies", func(t *testing.T) {
+		expect := model.UpdateDependencyList{Dependencies: []model.Dependency{a, b}}
+		actual := model.UpdateDependencyList{Dependencies: []model.Dependency{b, a}}
+		if err := compareUpdateDependencyList(expect, actual); err != nil {
+			t.Errorf("expected the lists to be equal, got %v", err)
+		}
+		if actual.Dependencies[0].Name != "b" {
+			t.Error("expected the actual list to not be reordered")
+		}
+	})
+
+	t.Run("detects different dependencies", func(t *testing.T) {
+		expect := model.UpdateDependencyList{Dependencies: []model.Dependency{a, b}}
+		actual := model.UpdateDependencyList{Dependencies: []model.Dependency{b, b}}
+		if err := compareUpdateDependencyList(expect, actual); err == nil {
+			t.Error("expected the lists to differ")
+		}
+	})
+}
+
+func TestDiffDependencyLists(t *testing.T) {
+	v1, v2 := "1.0.0", "2.0.0"
+	expect := model.UpdateDependencyList{Dependencies: []model.Dependency{
+		{Name: "a", Version: &v1},
+		{Name: "b", Version: &v1},
+		{Name: "c", Version: &v1},
+	}}
+	actual := model.UpdateDependencyList{Dependencies: []model.Dependency{
+		{Name: "d", Version: &v1},
+		{Name: "b", Version: &v2},
+		{Name: "a", Version: &v1},
+	}}
+
+	diff := DiffDependencyLists(expect, actual)
+	if len(diff.Unexpected) != 1 || diff.Unexpected[0].Name != "d" {
+		t.Errorf("expected d to be unexpected, got %v", diff.Unexpected)
+	}
+	if len(diff.Missing) != 1 || diff.Missing[0].Name != "c" {
+		t.Errorf("expected c to be missing, got %v", diff.Missing)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].Expect.Name != "b" || *diff.Changed[0].Actual.Version != v2 {
+		t.Errorf("expected b to be changed, got %v", diff.Changed)
+	}
+
+	want := "unexpected dependency d\nmissing dependency c\nchanged dependency b: version: expected \"1.0.0\" got \"2.0.0\""
+	if diff.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, diff.String())
+	}
+}
+
+func TestDetectRemovedDependencies(t *testing.T) {
+	v1, v2 := "1.0.0", "2.0.0"
+	prev := model.UpdateDependencyList{Dependencies: []model.Dependency{
+		{Name: "a", Version: &v1},
+		{Name: "b", Version: &v1},
+		{Name: "c", Version: &v1, Ecosystem: "npm"},
+	}}
+	curr := model.UpdateDependencyList{Dependencies: []model.Dependency{
+		{Name: "a", Version: &v2},
+		{Name: "c", Version: &v1, Ecosystem: "pip"},
+		{Name: "d", Version: &v1},
+	}}
+
+	removed := DetectRemovedDependencies(prev, curr)
+	if !reflect.DeepEqual(removed, []string{"b", "c"}) {
+		t.Errorf("expected b and c to be removed, got %v", removed)
+	}
+	if removed = DetectRemovedDependencies(model.UpdateDependencyList{}, curr); removed != nil {
+		t.Errorf("expected nothing removed without a previous list, got %v", removed)
+	}
+}
+
+func TestAPI_removedDependencyWarning(t *testing.T) {
+	var out bytes.Buffer
+	api := NewAPI(nil, nil, WithStructuredLogger(slog.New(slog.NewJSONHandler(&out, nil))))
+	defer api.Stop()
+
+	for _, body := range []string{
+		`{"data":{"dependencies":[{"name":"a"},{"name":"b"}],"dependency_files":["/go.mod"]}}`,
+		`{"data":{"dependencies":[{"name":"a"}],"dependency_files":["/go.mod"]}}`,
+	} {
+		request := httptest.NewRequest("POST", "/update_jobs/1/update_dependency_list", strings.NewReader(body))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single JSON log entry, got %q", out.String())
+	}
+	if entry["level"] != "WARN" || entry["dependency"] != "b" {
+		t.Errorf("expected a warning that b was removed, got %v", entry)
+	}
+	if len(api.Errors) != 0 {
+		t.Errorf("expected a removal not to be an error, got %v", api.Errors)
+	}
+	if api.PreviousList == nil || len(api.PreviousList.Dependencies) != 1 {
+		t.Errorf("expected the last list to be kept, got %v", api.PreviousList)
+	}
+}
+
+func Test_compareUpdateDependencyList_byEcosystem(t *testing.T) {
+	v1, v2 := "1.0.0", "2.0.0"
+	expect := model.UpdateDependencyList{Dependencies: []model.Dependency{
+		{Name: "requests", Version: &v1, Ecosystem: "pip"},
+		{Name: "lodash", Version: &v1, Ecosystem: "npm"},
+		{Name: "react", Version: &v1, Ecosystem: "npm"},
+	}}
+	actual := model.UpdateDependencyList{Dependencies: []model.Dependency{
+		{Name: "requests", Version: &v1, Ecosystem: "pip"},
+		{Name: "lodash", Version: &v2, Ecosystem: "npm"},
+		// the same name in another ecosystem isn't the same dependency
+		{Name: "react", Version: &v1, Ecosystem: "pip"},
+	}}
+
+	err := compareUpdateDependencyList(expect, actual)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	want := "npm dependencies differ:\n" +
+		"missing dependency react\n" +
+		"changed dependency lodash: version: expected \"1.0.0\" got \"2.0.0\"\n" +
+		"pip dependencies differ:\n" +
+		"unexpected dependency react"
+	if !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("expected the error to end with:\n%s\ngot:\n%s", want, err)
+	}
+}
+
+func TestAPI_binaryFiles(t *testing.T) {
+	file := func(content string) model.DependencyFile {
+		return model.DependencyFile{Name: "logo.png", Directory: "/", Content: content, ContentEncoding: "base64", Type: "file"}
+	}
+	expected := []model.Output{{
+		Type: "create_pull_request",
+		Expect: model.UpdateWrapper{Data: model.CreatePullRequest{
+			BaseCommitSha:          "1234",
+			UpdatedDependencyFiles: []model.DependencyFile{file("aGVsbG8gd29ybGQ=")},
+		}},
+	}}
+	send := func(content string) error {
+		api := NewAPI(expected, nil)
+		defer api.Stop()
+		body, err := json.Marshal(model.UpdateWrapper{Data: model.CreatePullRequest{
+			BaseCommitSha:          "1234",
+			UpdatedDependencyFiles: []model.DependencyFile{file(content)},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return api.InjectRequest("create_pull_request", body)
+	}
+
+	// the updater wraps base64 lines, which changes the hash but not the content
+	if err := send("aGVsbG8g\nd29ybGQ=\n"); err != nil {
+		t.Errorf("expected the same bytes to match, got %v", err)
+	}
+	if err := send("aGVsbG8gdGhlcmU="); err == nil || !strings.Contains(err.Error(), "binary content of /logo.png: expected 11 bytes got 11 bytes that differ") {
+		t.Errorf("expected a binary content error, got %v", err)
+	}
+	var decodeErr *DecodeError
+	if err := send("not base64!"); !errors.As(err, &decodeErr) {
+		t.Errorf("expected a decode error for invalid base64, got %v", err)
+	}
+}
+
+func Test_compareCreatePullRequest_ContentHash(t *testing.T) {
+	file := func(content, hash string) model.DependencyFile {
+		return model.DependencyFile{Name: "yarn.lock", Directory: "/", Content: content, ContentHash: hash, Type: "file"}
+	}
+	// sha256 of "hello world"
+	hash := "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	expect := model.CreatePullRequest{UpdatedDependencyFiles: []model.DependencyFile{file("", hash)}}
+
+	if err := compareCreatePullRequest(expect, model.CreatePullRequest{UpdatedDependencyFiles: []model.DependencyFile{file("hello world", "")}}); err != nil {
+		t.Errorf("expected content with the same hash to match, got %v", err)
+	}
+	if expect.UpdatedDependencyFiles[0].ContentHash != hash {
+		t.Error("expected the expectation to be left alone")
+	}
+
+	err := compareCreatePullRequest(expect, model.CreatePullRequest{UpdatedDependencyFiles: []model.DependencyFile{file("hello there", "")}})
+	want := "unexpected body for create_pull_request:\ncontent_hash of /yarn.lock: expected " + hash + " got "
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("expected an error starting with %q, got %v", want, err)
+	}
+}
+
+func TestAPI_recordsContentHashOfLargeFiles(t *testing.T) {
+	large := strings.Repeat("a", largeFileSize+1)
+	send := func(api *API) error {
+		body, err := json.Marshal(model.UpdateWrapper{Data: model.CreatePullRequest{
+			BaseCommitSha: "1234",
+			UpdatedDependencyFiles: []model.DependencyFile{
+				{Name: "yarn.lock", Directory: "/", Content: large, Type: "file"},
+				{Name: "package.json", Directory: "/", Content: "{}", Type: "file"},
+			},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return api.InjectRequest("create_pull_request", body)
+	}
+
+	recorder := NewAPI(nil, nil)
+	defer recorder.Stop()
+	if err := send(recorder); err != nil {
+		t.Fatal(err)
+	}
+	files := recorder.Actual.Output[0].Expect.Data.(model.CreatePullRequest).UpdatedDependencyFiles
+	if files[0].Content != "" || files[0].ContentHash != contentHash(large) {
+		t.Errorf("expected the large file to be recorded as a hash, got %d bytes of content and hash %q", len(files[0].Content), files[0].ContentHash)
+	}
+	if files[1].Content != "{}" || files[1].ContentHash != "" {
+		t.Errorf("expected the small file to be recorded as is, got %+v", files[1])
+	}
+
+	// the recording works as an expectation for the same call
+	api := NewAPI(recorder.Actual.Output, nil)
+	defer api.Stop()
+	if err := send(api); err != nil {
+		t.Errorf("expected the recording to match, got %v", err)
+	}
+}
+
+func TestAPI_StopWithTimeout(t *testing.T) {
+	api := NewAPI(nil, nil)
+	api.StopWithTimeout(time.Second)
+
+	_, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/", api.Port()))
+	if err == nil {
+		t.Error("expected the server to be stopped")
+	}
+}
+
+func TestAPI_Snapshot(t *testing.T) {
+	api := NewAPI(nil, nil)
+	defer api.Stop()
+
+	send := func(body string) {
+		request := httptest.NewRequest("POST", "/update_jobs/1/update_dependency_list", strings.NewReader(body))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+	send(`{"data":{"dependencies":[{"name":"a","requirements":[],"version":"1.0.0"}],"dependency_files":["/go.mod"]}}`)
+
+	snapshot := api.Snapshot()
+	send(`{"data":{"dependencies":[],"dependency_files":["/go.mod"]}}`)
+	snapshot.Output[0].Expect.Data.(model.UpdateDependencyList).DependencyFiles[0] = "changed"
+
+	if len(snapshot.Output) != 1 {
+		t.Errorf("expected the snapshot to not see later calls, got %v", snapshot.Output)
+	}
+	if api.Actual.Output[0].Expect.Data.(model.UpdateDependencyList).DependencyFiles[0] != "/go.mod" {
+		t.Error("expected the snapshot to be a deep copy")
+	}
+}
+
+func TestAPI_Concurrency(t *testing.T) {
+	expected := []model.Output{{
+		Type:   "mark_as_processed",
+		Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}},
+	}}
+	api := NewAPI(expected, nil)
+	defer api.Stop()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/update_jobs/1/mark_as_processed", api.Port())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			resp, err := http.Post(url, "application/json", strings.NewReader(`{"data":{"base-commit-sha":"1234"}}`))
+			if err == nil {
+				resp.Body.Close()
+			}
+		}
+	}()
+
+	// run with -race to check these don't race with the handler
+	for i := 0; i < 20; i++ {
+		_ = api.Snapshot()
+		_ = api.CallLog()
+		_ = api.ExpectationsMet()
+		_ = api.UnmetExpectations()
+		_ = api.Metrics()
+	}
+	<-done
+
+	if calls := api.CallLog(); len(calls) != 20 {
+		t.Errorf("expected 20 calls, got %d", len(calls))
+	}
+}
+
+func TestWithSemverEquivalence(t *testing.T) {
+	version := "1.2"
+	expected := []model.Output{{
+		Type: "create_pull_request",
+		Expect: model.UpdateWrapper{Data: model.CreatePullRequest{
+			Dependencies: []model.Dependency{{Name: "foo", Version: &version, PreviousVersion: "1.1"}},
+		}},
+	}}
+	send := func(api *API, version string) {
+		body := fmt.Sprintf(`{"data":{"base-commit-sha":"","dependencies":[{"name":"foo","requirements":null,"version":%q,"previous-version":"1.1.0"}],"updated-dependency-files":null,"pr-title":"","pr-body":"","commit-message":"","dependency-group":null}}`, version)
+		request := httptest.NewRequest("POST", "/update_jobs/1/create_pull_request", strings.NewReader(body))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	api := NewAPI(expected, nil, WithSemverEquivalence())
+	defer api.Stop()
+	send(api, "1.2.0")
+	if len(api.Errors) != 0 {
+		t.Errorf("expected equivalent versions to match, got %v", api.Errors)
+	}
+
+	api.Reset(expected)
+	send(api, "1.3.0")
+	if len(api.Errors) != 1 {
+		t.Errorf("expected different versions to not match, got %v", api.Errors)
+	}
+}
+
+func TestAPI_RespondWith(t *testing.T) {
+	expected := []model.Output{
+		{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}}},
+	}
+	api := NewAPI(expected, nil)
+	defer api.Stop()
+
+	api.RespondWith("mark_as_processed", http.StatusTooManyRequests, []byte("slow down"))
+	api.RespondWith("mark_as_processed", http.StatusServiceUnavailable, nil)
+
+	var statuses []int
+	for i := 0; i < 3; i++ {
+		request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(`{"data":{"base-commit-sha":"1234"}}`))
+		response := httptest.NewRecorder()
+		api.ServeHTTP(response, request)
+		statuses = append(statuses, response.Code)
+		if i == 0 && response.Body.String() != "slow down" {
+			t.Errorf("expected the queued body, got %q", response.Body.String())
+		}
+	}
+
+	if want := []int{http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusOK}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("expected statuses %v, got %v", want, statuses)
+	}
+	if len(api.Errors) != 0 || !api.ExpectationsMet() || len(api.CallLog()) != 1 {
+		t.Errorf("expected only the last request to be processed, got %v", api.Errors)
+	}
+}
+
+func TestAPI_SetResponse(t *testing.T) {
+	expected := []model.Output{
+		{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}}},
+	}
+	api := NewAPI(expected, nil)
+	defer api.Stop()
+
+	api.SetResponse("mark_as_processed", http.StatusAccepted, http.Header{"Content-Type": {"application/json"}}, []byte(`{"ok":true}`))
+
+	request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(`{"data":{"base-commit-sha":"1234"}}`))
+	response := httptest.NewRecorder()
+	api.ServeHTTP(response, request)
+	if response.Code != http.StatusAccepted || response.Header().Get("Content-Type") != "application/json" || response.Body.String() != `{"ok":true}` {
+		t.Errorf("expected the configured response, got %d %v %q", response.Code, response.Header(), response.Body.String())
+	}
+	if len(api.Errors) != 0 || !api.ExpectationsMet() {
+		t.Errorf("expected the request to still be checked, got %v", api.Errors)
+	}
+
+	// other kinds aren't affected
+	request = httptest.NewRequest("POST", "/update_jobs/1/record_update_job_error", strings.NewReader(`{"data":{"error-type":"unknown"}}`))
+	response = httptest.NewRecorder()
+	api.ServeHTTP(response, request)
+	if response.Code != http.StatusOK || response.Body.Len() != 0 {
+		t.Errorf("expected an empty 200, got %d %q", response.Code, response.Body.String())
+	}
+
+	// a payload that can't be decoded is still rejected
+	request = httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(`{"data":{"unknown":"value"}}`))
+	response = httptest.NewRecorder()
+	api.ServeHTTP(response, request)
+	if response.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected a decode error status, got %d", response.Code)
+	}
+}
+
+func TestAPI_InjectRequest(t *testing.T) {
+	expected := []model.Output{
+		{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}}},
+		{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "5678"}}},
+	}
+	api := NewAPI(expected, nil)
+	defer api.Stop()
+
+	tests := []struct {
+		name    string
+		kind    string
+		payload string
+		wantErr string
+	}{
+		{"matches", "mark_as_processed", `{"data":{"base-commit-sha":"1234"}}`, ""},
+		{"unexpected body", "mark_as_processed", `{"data":{"base-commit-sha":"0000"}}`, "unexpected body"},
+		{"unknown kind", "unknown", `{"data":{}}`, "unexpected output type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := api.InjectRequest(tt.kind, []byte(tt.payload))
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)) {
+				t.Errorf("expected an error starting with %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestWithMaxBodySize(t *testing.T) {
+	api := NewAPI(nil, nil, WithMaxBodySize(64))
+	defer api.Stop()
+
+	body := fmt.Sprintf(`{"data":{"base-commit-sha":%q}}`, strings.Repeat("a", 64))
+	request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(body))
+	response := httptest.NewRecorder()
+	api.ServeHTTP(response, request)
+
+	if response.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected a 413, got %d", response.Code)
+	}
+	if len(api.Errors) != 1 || api.Errors[0].Error() != "request body for mark_as_processed exceeds the limit of 64 bytes" {
+		t.Errorf("expected a size error, got %v", api.Errors)
+	}
+}
+
+func TestAPI_BytesReceived(t *testing.T) {
+	api := NewAPI(nil, nil)
+	defer api.Stop()
+
+	markAsProcessed := `{"data":{"base-commit-sha":"1234"}}`
+	// the trailing whitespace is past where the decoder stops but still counts
+	recordMetrics := `{"data":[]}` + "\n\n"
+	for _, call := range [][2]string{
+		{"mark_as_processed", markAsProcessed},
+		{"mark_as_processed", markAsProcessed},
+		{"record_ecosystem_versions", recordMetrics},
+	} {
+		request := httptest.NewRequest("POST", "/update_jobs/1/"+call[0], strings.NewReader(call[1]))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	expected := map[string]int64{
+		"mark_as_processed":         int64(2 * len(markAsProcessed)),
+		"record_ecosystem_versions": int64(len(recordMetrics)),
+	}
+	if received := api.BytesReceivedByKind(); !reflect.DeepEqual(received, expected) {
+		t.Errorf("expected %v, got %v", expected, received)
+	}
+	if total := api.TotalBytesReceived(); total != expected["mark_as_processed"]+expected["record_ecosystem_versions"] {
+		t.Errorf("expected the total to be the sum of every kind, got %d", total)
+	}
+}
+
+func TestAPI_malformedEcosystemVersion(t *testing.T) {
+	api := NewAPI([]model.Output{{
+		Type: "record_ecosystem_versions",
+		Expect: model.UpdateWrapper{Data: model.RecordEcosystemVersions{
+			EcosystemVersions: map[string]any{"package_managers": map[string]any{"gomod": ""}},
+		}},
+	}}, nil)
+	defer api.Stop()
+
+	err := api.InjectRequest("record_ecosystem_versions", []byte(`{"data":{"ecosystem_versions":{"package_managers":{"gomod":""}}}}`))
+	if err == nil || !strings.Contains(err.Error(), `version of package manager gomod is malformed: ""`) {
+		t.Errorf("expected a malformed version error, got %v", err)
+	}
+}
+
+func TestAPI_ServeHTTP_cancelled(t *testing.T) {
+	api := NewAPI(nil, nil)
+	defer api.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(`{"data":{"base-commit-sha":"1234"}}`)).WithContext(ctx)
+	api.ServeHTTP(httptest.NewRecorder(), request)
+
+	if len(api.Errors) != 1 || !errors.Is(api.Errors[0], context.Canceled) {
+		t.Errorf("expected a cancelled error, got %v", api.Errors)
+	}
+	if len(api.CallLog()) != 0 {
+		t.Error("expected the cancelled call to not be processed")
+	}
+}
+
+func TestAPI_recordUpdateJobErrors(t *testing.T) {
+	expected := []model.Output{
+		{Type: "record_update_job_error", Expect: model.UpdateWrapper{Data: model.RecordUpdateJobError{
+			ErrorType: "dependency_file_not_found",
+		}}},
+		{Type: "record_update_job_unknown_error", Expect: model.UpdateWrapper{Data: model.RecordUpdateJobUnknownError{
+			ErrorType:    "unknown_error",
+			ErrorDetails: map[string]any{"error-message": "boom"},
+		}}},
+	}
+
+	t.Run("known and unknown errors are expected independently", func(t *testing.T) {
+		api := NewAPI(expected, nil)
+		defer api.Stop()
+		if err := api.InjectRequest("record_update_job_error", []byte(`{"data":{"error-type":"dependency_file_not_found","error-details":null}}`)); err != nil {
+			t.Error(err)
+		}
+		if err := api.InjectRequest("record_update_job_unknown_error", []byte(`{"data":{"error-type":"unknown_error","error-details":{"error-message":"boom"}}}`)); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("an unknown error doesn't satisfy a known error", func(t *testing.T) {
+		api := NewAPI(expected, nil)
+		defer api.Stop()
+		err := api.InjectRequest("record_update_job_unknown_error", []byte(`{"data":{"error-type":"dependency_file_not_found","error-details":null}}`))
+		var unexpectedType *UnexpectedTypeError
+		if !errors.As(err, &unexpectedType) {
+			t.Errorf("expected an unexpected type error, got %v", err)
+		}
+	})
+}
+
+func TestWithMaxRequests(t *testing.T) {
+	api := NewAPI(nil, nil, WithMaxRequests(2))
+	defer api.Stop()
+
+	var statuses []int
+	for i := 0; i < 4; i++ {
+		request := httptest.NewRequest("POST", "/update_jobs/1/update_dependency_list", strings.NewReader(`{"data":{"dependencies":[],"dependency_files":[]}}`))
+		response := httptest.NewRecorder()
+		api.ServeHTTP(response, request)
+		statuses = append(statuses, response.Code)
+	}
+
+	if want := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("expected statuses %v, got %v", want, statuses)
+	}
+	if len(api.Errors) != 2 || api.Errors[1].Error() != "too many requests: received 4 calls, the limit is 2" {
+		t.Errorf("expected an error for each call over the limit, got %v", api.Errors)
+	}
 }
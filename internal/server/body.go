@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// DefaultMaxBodySize is the largest request body the API accepts unless WithMaxBodySize is used
+const DefaultMaxBodySize = 50 << 20
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// WithMaxBodySize overrides the largest request body the API accepts, larger requests get a 413
+func WithMaxBodySize(n int64) APIOption {
+	return func(a *API) {
+		a.maxBodySize = n
+	}
+}
+
+// BytesReceivedByKind returns the size of the request bodies received for each kind of call, e.g. create_pull_request
+func (a *API) BytesReceivedByKind() map[string]int64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	received := make(map[string]int64, len(a.bytesReceived))
+	for kind, n := range a.bytesReceived {
+		received[kind] = n
+	}
+	return received
+}
+
+// TotalBytesReceived returns the size of every request body received
+func (a *API) TotalBytesReceived() int64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var total int64
+	for _, n := range a.bytesReceived {
+		total += n
+	}
+	return total
+}
+
+// recordBytesReceived adds to the body size of the kind, the caller must hold the lock
+func (a *API) recordBytesReceived(kind string, n int64) {
+	if a.bytesReceived == nil {
+		a.bytesReceived = map[string]int64{}
+	}
+	a.bytesReceived[kind] += n
+}
+
+// limitedBody stops reading a request body once it's over the size limit or the request is cancelled,
+// so an updater that disconnects part way through a large payload doesn't hold up the server
+type limitedBody struct {
+	ctx      context.Context
+	r        io.Reader
+	max      int64
+	read     int64
+	exceeded bool
+}
+
+func newLimitedBody(ctx context.Context, r io.Reader, max int64) *limitedBody {
+	// read one byte past the limit to tell a body that's exactly the limit from one that's over it
+	return &limitedBody{ctx: ctx, r: io.LimitReader(r, max+1), max: max}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if err := b.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+	if b.read > b.max {
+		b.exceeded = true
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
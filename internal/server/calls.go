@@ -0,0 +1,91 @@
+package server
+
+import (
+	"time"
+)
+
+// APICall is a call the API received and successfully decoded
+type APICall struct {
+	// Seq is the order the call was received in, starting at 1
+	Seq int
+	// Kind is the type of call, e.g. create_pull_request
+	Kind string
+	// ReceivedAt is when the call was received
+	ReceivedAt time.Time
+	// Data is the decoded payload, e.g. model.CreatePullRequest
+	Data any
+}
+
+// WithCallObserver calls fn with each call as it's received, for example to display progress.
+// fn is called while the API is handling the request and holds its lock, so it should return quickly and
+// mustn't call the API, e.g. CallLog, which would deadlock.
+func WithCallObserver(fn func(APICall)) APIOption {
+	return func(a *API) {
+		a.callObservers = append(a.callObservers, fn)
+	}
+}
+
+// CallLog returns every call received, including those that failed expectations
+func (a *API) CallLog() []APICall {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	calls := make([]APICall, len(a.callLog))
+	copy(calls, a.callLog)
+	return calls
+}
+
+// ForEachCall calls fn with each call received so far, in order. It's safe to use while the server is
+// running, before Complete, since the calls can't change until it returns. fn must not call other API methods.
+func (a *API) ForEachCall(fn func(APICall)) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, call := range a.callLog {
+		fn(call)
+	}
+}
+
+// ForEachCallOfKind is like ForEachCall but only for calls of the given kind, e.g. create_pull_request
+func (a *API) ForEachCallOfKind(kind string, fn func(APICall)) {
+	a.ForEachCall(func(call APICall) {
+		if call.Kind == kind {
+			fn(call)
+		}
+	})
+}
+
+// LastCall returns the most recent call received, or nil if there haven't been any
+func (a *API) LastCall() *APICall {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if len(a.callLog) == 0 {
+		return nil
+	}
+	call := a.callLog[len(a.callLog)-1]
+	return &call
+}
+
+// LastCallOfKind returns the most recent call of the given kind, or nil if there haven't been any
+func (a *API) LastCallOfKind(kind string) *APICall {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for i := len(a.callLog) - 1; i >= 0; i-- {
+		if a.callLog[i].Kind == kind {
+			call := a.callLog[i]
+			return &call
+		}
+	}
+	return nil
+}
+
+func (a *API) logCall(kind string, receivedAt time.Time, data any) {
+	call := APICall{
+		Seq:        len(a.callLog) + 1,
+		Kind:       kind,
+		ReceivedAt: receivedAt,
+		Data:       data,
+	}
+	a.callLog = append(a.callLog, call)
+	for _, fn := range a.callObservers {
+		fn(call)
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dependabot/cli/internal/model"
+)
+
+func TestAPI_CallLog(t *testing.T) {
+	expected := []model.Output{{
+		Type:   "mark_as_processed",
+		Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}},
+	}}
+	api := NewAPI(expected, nil)
+	defer api.Stop()
+
+	bodies := []string{`{"data":{"base-commit-sha":"5678"}}`, `{"data":{"unknown":"value"}}`}
+	for _, body := range bodies {
+		request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(body))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	calls := api.CallLog()
+	if len(calls) != 1 {
+		t.Fatalf("expected only the decoded call to be logged, got %v", calls)
+	}
+	if calls[0].Seq != 1 || calls[0].Kind != "mark_as_processed" || calls[0].ReceivedAt.IsZero() {
+		t.Errorf("unexpected call %+v", calls[0])
+	}
+	if calls[0].Data.(model.MarkAsProcessed).BaseCommitSha != "5678" {
+		t.Errorf("expected the call that failed its expectation to be logged, got %v", calls[0].Data)
+	}
+}
+
+func TestWithCallObserver(t *testing.T) {
+	var observed []APICall
+	api := NewAPI(nil, nil, WithCallObserver(func(call APICall) {
+		observed = append(observed, call)
+	}))
+	defer api.Stop()
+
+	request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(`{"data":{"base-commit-sha":"1234"}}`))
+	api.ServeHTTP(httptest.NewRecorder(), request)
+
+	if len(observed) != 1 || observed[0].Seq != 1 || observed[0].Kind != "mark_as_processed" {
+		t.Errorf("expected the call to be observed, got %+v", observed)
+	}
+}
+
+func TestAPI_LastCall(t *testing.T) {
+	api := NewAPI(nil, nil)
+	defer api.Stop()
+
+	if api.LastCall() != nil || api.LastCallOfKind("mark_as_processed") != nil {
+		t.Error("expected no calls yet")
+	}
+
+	for _, kind := range []string{"mark_as_processed", "increment_metric", "mark_as_processed", "increment_metric"} {
+		body := `{"data":{}}`
+		if kind == "increment_metric" {
+			body = `{"data":{"metric":"updater.started"}}`
+		}
+		request := httptest.NewRequest("POST", "/update_jobs/1/"+kind, strings.NewReader(body))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	if last := api.LastCall(); last == nil || last.Seq != 4 || last.Kind != "increment_metric" {
+		t.Errorf("expected the fourth call, got %+v", last)
+	}
+	if last := api.LastCallOfKind("mark_as_processed"); last == nil || last.Seq != 3 {
+		t.Errorf("expected the third call, got %+v", last)
+	}
+	if api.LastCallOfKind("create_pull_request") != nil {
+		t.Error("expected no create_pull_request call")
+	}
+}
+
+func TestAPI_ForEachCall(t *testing.T) {
+	api := NewAPI(nil, nil)
+	defer api.Stop()
+
+	for _, kind := range []string{"mark_as_processed", "increment_metric", "mark_as_processed"} {
+		body := `{"data":{}}`
+		if kind == "increment_metric" {
+			body = `{"data":{"metric":"updater.started"}}`
+		}
+		request := httptest.NewRequest("POST", "/update_jobs/1/"+kind, strings.NewReader(body))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	var all []int
+	api.ForEachCall(func(call APICall) {
+		all = append(all, call.Seq)
+	})
+	if len(all) != 3 || all[0] != 1 || all[2] != 3 {
+		t.Errorf("expected every call in order, got %v", all)
+	}
+
+	var marked []int
+	api.ForEachCallOfKind("mark_as_processed", func(call APICall) {
+		marked = append(marked, call.Seq)
+	})
+	if len(marked) != 2 || marked[0] != 1 || marked[1] != 3 {
+		t.Errorf("expected only the mark_as_processed calls, got %v", marked)
+	}
+}
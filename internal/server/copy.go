@@ -0,0 +1,59 @@
+package server
+
+import "reflect"
+
+// deepCopy returns a copy of v that shares no pointers, slices, or maps with it
+func deepCopy[T any](v T) T {
+	src := reflect.ValueOf(&v).Elem()
+	dst := reflect.New(src.Type()).Elem()
+	copyValue(dst, src)
+	return dst.Interface().(T)
+}
+
+func copyValue(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if src.IsNil() {
+			return
+		}
+		elem := reflect.New(src.Type().Elem())
+		copyValue(elem.Elem(), src.Elem())
+		dst.Set(elem)
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		elem := reflect.New(src.Elem().Type()).Elem()
+		copyValue(elem, src.Elem())
+		dst.Set(elem)
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		elems := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			copyValue(elems.Index(i), src.Index(i))
+		}
+		dst.Set(elems)
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		elems := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			elem := reflect.New(iter.Value().Type()).Elem()
+			copyValue(elem, iter.Value())
+			elems.SetMapIndex(iter.Key(), elem)
+		}
+		dst.Set(elems)
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				copyValue(dst.Field(i), src.Field(i))
+			}
+		}
+	default:
+		dst.Set(src)
+	}
+}
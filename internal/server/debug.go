@@ -0,0 +1,61 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dependabot/cli/internal/model"
+	"gopkg.in/yaml.v3"
+)
+
+// WithInteractiveDebug pauses before each call is checked against the expectations, printing the call and
+// the next expectation to out and waiting for a line from in. Pressing Enter checks the call as usual,
+// typing skip treats the expectation as met without comparing. It's meant for stepping through a failing scenario.
+func WithInteractiveDebug(in io.Reader, out io.Writer) APIOption {
+	return func(a *API) {
+		a.debugIn = bufio.NewReader(in)
+		a.debugOut = out
+	}
+}
+
+// debugPrompt shows the call and the next expectation and reports whether the user chose to skip it
+func (a *API) debugPrompt(kind string, actual *model.UpdateWrapper) bool {
+	_, _ = fmt.Fprintf(a.debugOut, "--- received %s\n%s", kind, debugYAML(actual))
+	if a.cursor < len(a.Expectations) {
+		expect := a.Expectations[a.cursor]
+		_, _ = fmt.Fprintf(a.debugOut, "--- expectation %d of %d: %s\n%s", a.cursor+1, len(a.Expectations), expect.Type, debugYAML(expect.Expect))
+	} else {
+		_, _ = fmt.Fprintln(a.debugOut, "--- no expectations left")
+	}
+	_, _ = fmt.Fprint(a.debugOut, "press Enter to compare, or type skip to skip the expectation: ")
+
+	// at the end of the input there's nobody to ask, so carry on comparing
+	line, _ := a.debugIn.ReadString('\n')
+	return strings.TrimSpace(line) == "skip"
+}
+
+// skipExpectation marks the expectation at the cursor as met, the caller must hold the lock
+func (a *API) skipExpectation() {
+	if a.cursor >= len(a.Expectations) {
+		return
+	}
+	if a.matched != nil {
+		a.matched[a.cursor] = true
+	}
+	a.met++
+	a.cursor++
+	for a.matched != nil && a.cursor < len(a.Expectations) && a.matched[a.cursor] {
+		a.cursor++
+	}
+	a.checkDone()
+}
+
+func debugYAML(v any) string {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v\n", v)
+	}
+	return string(data)
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dependabot/cli/internal/model"
+)
+
+func TestWithInteractiveDebug(t *testing.T) {
+	markAsProcessed := func(sha string) model.Output {
+		return model.Output{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: sha}}}
+	}
+	var out bytes.Buffer
+	// compare the first call, skip the second
+	in := strings.NewReader("\nskip\n")
+	api := NewAPI([]model.Output{markAsProcessed("1"), markAsProcessed("2")}, nil, WithInteractiveDebug(in, &out))
+	defer api.Stop()
+
+	for _, sha := range []string{"1", "x"} {
+		if err := api.InjectRequest("mark_as_processed", []byte(`{"data":{"base-commit-sha":"`+sha+`"}}`)); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	}
+	api.MustComplete(t)
+
+	for _, want := range []string{
+		"--- received mark_as_processed\ndata:\n    base-commit-sha: x\n",
+		"--- expectation 2 of 2: mark_as_processed\ndata:\n    base-commit-sha: \"2\"\n",
+		"press Enter to compare, or type skip to skip the expectation: ",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected the output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
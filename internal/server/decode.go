@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// bodyDecoder decodes a payload into out, rejecting any field out doesn't have
+type bodyDecoder func(data io.Reader, out any) error
+
+// bodyDecoderFor picks the decoder for a request's Content-Type. The updater sends JSON, anything
+// other than JSON is decoded as YAML, which is also what's used for requests without a Content-Type.
+func bodyDecoderFor(contentType string) bodyDecoder {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		return decodeJSON
+	}
+	return decodeYAML
+}
+
+func decodeYAML(data io.Reader, out any) error {
+	decoder := yaml.NewDecoder(data)
+	decoder.KnownFields(true)
+	return decoder.Decode(out)
+}
+
+// decodeJSON decodes the numbers in untyped fields like error-details the way YAML does,
+// as an int when they're whole, so the payload compares equal to an expectation from a scenario file.
+func decodeJSON(data io.Reader, out any) error {
+	decoder := json.NewDecoder(data)
+	decoder.DisallowUnknownFields()
+	decoder.UseNumber()
+	if err := decoder.Decode(out); err != nil {
+		return err
+	}
+	yamlNumbers(reflect.ValueOf(out))
+	return nil
+}
+
+// yamlNumbers replaces each json.Number under v with an int, or a float64 if it isn't whole
+func yamlNumbers(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			yamlNumbers(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				yamlNumbers(v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			yamlNumbers(v.Index(i))
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			// map values can't be set in place, so convert a copy and put it back
+			value := reflect.New(iter.Value().Type()).Elem()
+			value.Set(iter.Value())
+			yamlNumbers(value)
+			v.SetMapIndex(iter.Key(), value)
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		if n, ok := v.Interface().(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				v.Set(reflect.ValueOf(int(i)))
+			} else if f, err := n.Float64(); err == nil {
+				v.Set(reflect.ValueOf(f))
+			}
+			return
+		}
+		value := reflect.New(v.Elem().Type()).Elem()
+		value.Set(v.Elem())
+		yamlNumbers(value)
+		v.Set(value)
+	}
+}
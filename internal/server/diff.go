@@ -0,0 +1,496 @@
+package server
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/dependabot/cli/internal/model"
+)
+
+// fieldDiffs walks expect and actual in parallel and returns a line per field that differs,
+// named by its path through the payload, e.g. `dependencies[0].version: expected "1.2.0" got "1.3.0"`.
+func fieldDiffs(expect, actual any) []string {
+	var diffs []string
+	walkDiff("", reflect.ValueOf(expect), reflect.ValueOf(actual), &diffs)
+	return diffs
+}
+
+func walkDiff(path string, expect, actual reflect.Value, diffs *[]string) {
+	if !expect.IsValid() || !actual.IsValid() {
+		if expect.IsValid() != actual.IsValid() {
+			*diffs = append(*diffs, formatDiff(path, expect, actual))
+		}
+		return
+	}
+	if expect.Type() != actual.Type() {
+		*diffs = append(*diffs, formatDiff(path, expect, actual))
+		return
+	}
+
+	switch expect.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if expect.IsNil() || actual.IsNil() {
+			if expect.IsNil() != actual.IsNil() {
+				*diffs = append(*diffs, formatDiff(path, expect, actual))
+			}
+			return
+		}
+		walkDiff(path, expect.Elem(), actual.Elem(), diffs)
+	case reflect.Struct:
+		for i := 0; i < expect.NumField(); i++ {
+			field := expect.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			walkDiff(joinPath(path, fieldName(field)), expect.Field(i), actual.Field(i), diffs)
+		}
+	case reflect.Slice, reflect.Array:
+		if expect.Kind() == reflect.Slice && expect.IsNil() != actual.IsNil() && (expect.Len() > 0 || actual.Len() > 0) {
+			*diffs = append(*diffs, formatDiff(path, expect, actual))
+			return
+		}
+		n := max(expect.Len(), actual.Len())
+		for i := 0; i < n; i++ {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= actual.Len():
+				*diffs = append(*diffs, fmt.Sprintf("%s: expected %s got nothing", elemPath, formatValue(expect.Index(i))))
+			case i >= expect.Len():
+				*diffs = append(*diffs, fmt.Sprintf("%s: expected nothing got %s", elemPath, formatValue(actual.Index(i))))
+			default:
+				walkDiff(elemPath, expect.Index(i), actual.Index(i), diffs)
+			}
+		}
+	case reflect.Map:
+		keys := map[string]reflect.Value{}
+		for _, k := range expect.MapKeys() {
+			keys[fmt.Sprint(k.Interface())] = k
+		}
+		for _, k := range actual.MapKeys() {
+			keys[fmt.Sprint(k.Interface())] = k
+		}
+		names := make([]string, 0, len(keys))
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			k := keys[name]
+			elemPath := joinPath(path, name)
+			e, a := expect.MapIndex(k), actual.MapIndex(k)
+			switch {
+			case !a.IsValid():
+				*diffs = append(*diffs, fmt.Sprintf("%s: expected %s got nothing", elemPath, formatValue(e)))
+			case !e.IsValid():
+				*diffs = append(*diffs, fmt.Sprintf("%s: expected nothing got %s", elemPath, formatValue(a)))
+			default:
+				walkDiff(elemPath, e, a, diffs)
+			}
+		}
+	default:
+		if !reflect.DeepEqual(expect.Interface(), actual.Interface()) {
+			*diffs = append(*diffs, formatDiff(path, expect, actual))
+		}
+	}
+}
+
+func formatDiff(path string, expect, actual reflect.Value) string {
+	return fmt.Sprintf("%s: expected %s got %s", path, formatValue(expect), formatValue(actual))
+}
+
+func formatValue(v reflect.Value) string {
+	if !v.IsValid() {
+		return "nil"
+	}
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return "nil"
+	}
+	return fmt.Sprintf("%#v", v.Interface())
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// fieldName uses the same name as the scenario files so the path can be found in the YAML
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"yaml", "json"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// withoutFields returns a copy of v with the field at each path set to its zero value.
+// Paths are dot separated field names, e.g. "data.pr-body". A path through a slice applies to every element.
+func withoutFields(v any, paths []string) any {
+	if len(paths) == 0 || v == nil {
+		return v
+	}
+	value := reflect.New(reflect.TypeOf(v)).Elem()
+	value.Set(reflect.ValueOf(v))
+	for _, path := range paths {
+		path, ok := strings.CutPrefix(path, "data.")
+		if !ok {
+			continue
+		}
+		clearPath(value, strings.Split(path, "."))
+	}
+	return value.Interface()
+}
+
+func clearPath(v reflect.Value, path []string) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		elem := reflect.New(v.Type().Elem())
+		elem.Elem().Set(v.Elem())
+		clearPath(elem.Elem(), path)
+		v.Set(elem)
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		// copy so the caller's backing array is left alone
+		elems := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(elems, v)
+		for i := 0; i < elems.Len(); i++ {
+			clearPath(elems.Index(i), path)
+		}
+		v.Set(elems)
+	case reflect.Map:
+		if v.IsNil() {
+			return
+		}
+		key := reflect.ValueOf(path[0])
+		if !key.Type().AssignableTo(v.Type().Key()) {
+			return
+		}
+		elems := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			elems.SetMapIndex(iter.Key(), iter.Value())
+		}
+		if len(path) == 1 {
+			elems.SetMapIndex(key, reflect.Value{})
+		} else if elem := elems.MapIndex(key); elem.IsValid() {
+			copied := reflect.New(elem.Type()).Elem()
+			copied.Set(elem)
+			clearPath(copied, path[1:])
+			elems.SetMapIndex(key, copied)
+		}
+		v.Set(elems)
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		clearPath(elem, path)
+		v.Set(elem)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() || fieldName(field) != path[0] {
+				continue
+			}
+			if len(path) == 1 {
+				v.Field(i).Set(reflect.Zero(field.Type))
+			} else {
+				clearPath(v.Field(i), path[1:])
+			}
+		}
+	}
+}
+
+// nullFields returns the path of each field set to null in data, as decoded from a scenario file, whose type
+// in the payload t can't be nil. Fields that can be nil, like pointers and slices, are expected to be nil instead.
+func nullFields(data any, t reflect.Type, path string) []string {
+	values, ok := data.(map[string]any)
+	if !ok {
+		return nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var paths []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value, ok := values[fieldName(field)]
+		if !field.IsExported() || !ok {
+			continue
+		}
+		fieldPath := joinPath(path, fieldName(field))
+		if value != nil {
+			paths = append(paths, nullFields(value, field.Type, fieldPath)...)
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+		default:
+			paths = append(paths, fieldPath)
+		}
+	}
+	return paths
+}
+
+// DiffUpdatePullRequest summarizes which dependencies and files differ between two update_pull_request payloads
+func DiffUpdatePullRequest(expect, actual model.UpdatePullRequest) string {
+	var lines []string
+	for _, name := range missingFrom(expect.DependencyNames, actual.DependencyNames) {
+		lines = append(lines, fmt.Sprintf("dependency %s was not updated", name))
+	}
+	for _, name := range missingFrom(actual.DependencyNames, expect.DependencyNames) {
+		lines = append(lines, fmt.Sprintf("dependency %s was unexpectedly updated", name))
+	}
+
+	expectFiles := filesByPath(expect.UpdatedDependencyFiles)
+	actualFiles := filesByPath(actual.UpdatedDependencyFiles)
+	for _, path := range sortedKeys(expectFiles) {
+		actualFile, ok := actualFiles[path]
+		switch {
+		case !ok:
+			lines = append(lines, fmt.Sprintf("file %s was not updated", path))
+		case !reflect.DeepEqual(expectFiles[path], actualFile):
+			lines = append(lines, fmt.Sprintf("file %s has different changes", path))
+		}
+	}
+	for _, path := range sortedKeys(actualFiles) {
+		if _, ok := expectFiles[path]; !ok {
+			lines = append(lines, fmt.Sprintf("file %s was unexpectedly updated", path))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DependencyListDiff is the difference between the dependencies of two update_dependency_list payloads
+type DependencyListDiff struct {
+	// Unexpected are the dependencies that were only in actual
+	Unexpected []model.Dependency
+	// Missing are the dependencies that were only in expect
+	Missing []model.Dependency
+	// Changed are the dependencies in both whose version or metadata differ
+	Changed []DependencyChange
+}
+
+// DependencyChange is a dependency that's in both lists but differs
+type DependencyChange struct {
+	Expect model.Dependency
+	Actual model.Dependency
+}
+
+// DiffDependencyLists matches the dependencies of expect and actual by name, regardless of order
+func DiffDependencyLists(expect, actual model.UpdateDependencyList) DependencyListDiff {
+	var diff DependencyListDiff
+	remaining := slices.Clone(actual.Dependencies)
+	for _, e := range expect.Dependencies {
+		// prefer an identical dependency in case the same name appears with several versions
+		i := slices.IndexFunc(remaining, func(a model.Dependency) bool { return reflect.DeepEqual(e, a) })
+		if i < 0 {
+			i = slices.IndexFunc(remaining, func(a model.Dependency) bool { return a.Name == e.Name })
+		}
+		if i < 0 {
+			diff.Missing = append(diff.Missing, e)
+			continue
+		}
+		if !reflect.DeepEqual(e, remaining[i]) {
+			diff.Changed = append(diff.Changed, DependencyChange{Expect: e, Actual: remaining[i]})
+		}
+		remaining = slices.Delete(remaining, i, i+1)
+	}
+	diff.Unexpected = remaining
+	return diff
+}
+
+// DiffDependencyListsByEcosystem diffs the dependencies of each ecosystem separately, so a dependency is only
+// matched with one of the same ecosystem. Only the ecosystems that differ are returned.
+func DiffDependencyListsByEcosystem(expect, actual model.UpdateDependencyList) map[string]DependencyListDiff {
+	groups := map[string][2]model.UpdateDependencyList{}
+	for i, list := range []model.UpdateDependencyList{expect, actual} {
+		for _, dep := range list.Dependencies {
+			group := groups[dep.Ecosystem]
+			group[i].Dependencies = append(group[i].Dependencies, dep)
+			groups[dep.Ecosystem] = group
+		}
+	}
+	diffs := map[string]DependencyListDiff{}
+	for ecosystem, group := range groups {
+		if diff := DiffDependencyLists(group[0], group[1]); !diff.Empty() {
+			diffs[ecosystem] = diff
+		}
+	}
+	return diffs
+}
+
+// DetectRemovedDependencies returns the names of the dependencies in prev that aren't in curr, in the order of prev.
+// A dependency is identified by its name and ecosystem, so a version change isn't a removal.
+func DetectRemovedDependencies(prev, curr model.UpdateDependencyList) []string {
+	var removed []string
+	for _, p := range prev.Dependencies {
+		if !slices.ContainsFunc(curr.Dependencies, func(c model.Dependency) bool {
+			return c.Name == p.Name && c.Ecosystem == p.Ecosystem
+		}) {
+			removed = append(removed, p.Name)
+		}
+	}
+	return removed
+}
+
+// Empty is true when the dependency lists are the same
+func (d DependencyListDiff) Empty() bool {
+	return len(d.Unexpected) == 0 && len(d.Missing) == 0 && len(d.Changed) == 0
+}
+
+// String has a line for each unexpected or missing dependency and each field of a changed one
+func (d DependencyListDiff) String() string {
+	var lines []string
+	for _, dep := range d.Unexpected {
+		lines = append(lines, fmt.Sprintf("unexpected dependency %s", dep.Name))
+	}
+	for _, dep := range d.Missing {
+		lines = append(lines, fmt.Sprintf("missing dependency %s", dep.Name))
+	}
+	for _, change := range d.Changed {
+		for _, diff := range fieldDiffs(change.Expect, change.Actual) {
+			lines = append(lines, fmt.Sprintf("changed dependency %s: %s", change.Expect.Name, diff))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// mismatchOffset estimates where s stops matching pattern, since Go's regular expressions can't report a
+// partial match. It's the end of the longest match of a leading part of the pattern, split into single characters.
+func mismatchOffset(pattern, s string) int {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return 0
+	}
+	parts := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		parts = re.Sub
+	}
+	var steps []*syntax.Regexp
+	for _, part := range parts {
+		if part.Op != syntax.OpLiteral {
+			steps = append(steps, part)
+			continue
+		}
+		for _, r := range part.Rune {
+			steps = append(steps, &syntax.Regexp{Op: syntax.OpLiteral, Flags: part.Flags, Rune: []rune{r}})
+		}
+	}
+
+	var offset int
+	for i := range steps {
+		prefix, err := regexp.Compile((&syntax.Regexp{Op: syntax.OpConcat, Sub: steps[:i+1]}).String())
+		if err != nil {
+			break
+		}
+		loc := prefix.FindStringIndex(s)
+		if loc == nil {
+			break
+		}
+		offset = loc[1]
+	}
+	return offset
+}
+
+// excerpt returns up to n characters of s, with ... if it was cut short
+func excerpt(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n]) + "..."
+	}
+	return s
+}
+
+// missingFrom returns the values in a that aren't in b
+func missingFrom(a, b []string) []string {
+	var missing []string
+	for _, v := range a {
+		if !slices.Contains(b, v) {
+			missing = append(missing, v)
+		}
+	}
+	return missing
+}
+
+func filesByPath(files []model.DependencyFile) map[string]model.DependencyFile {
+	byPath := make(map[string]model.DependencyFile, len(files))
+	for _, file := range files {
+		byPath[path.Join(file.Directory, file.Name)] = file
+	}
+	return byPath
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// onlyFieldsSetIn returns a copy of actual with every field that is empty in expect set to its zero value
+func onlyFieldsSetIn(expect, actual any) any {
+	e, a := reflect.ValueOf(expect), reflect.ValueOf(actual)
+	if e.Kind() != reflect.Struct || e.Type() != a.Type() {
+		return actual
+	}
+	value := reflect.New(a.Type()).Elem()
+	value.Set(a)
+	for i := 0; i < e.NumField(); i++ {
+		if unsetIn(e, i) {
+			value.Field(i).Set(e.Field(i))
+		}
+	}
+	return value.Interface()
+}
+
+// unsetIn reports whether the expectation leaves field i empty. A field with a pattern, e.g. pr-title with
+// pr-title-pattern, counts as set so the pattern is matched against the actual value rather than an empty one.
+func unsetIn(e reflect.Value, i int) bool {
+	field := e.Field(i)
+	if !field.IsZero() && !((field.Kind() == reflect.Slice || field.Kind() == reflect.Map) && field.Len() == 0) {
+		return false
+	}
+	pattern := e.FieldByName(e.Type().Field(i).Name + "Pattern")
+	return !pattern.IsValid() || pattern.IsZero()
+}
+
+// onlyOptionalFieldsSetIn is like onlyFieldsSetIn for the optional fields, those that are omitempty,
+// so an empty required field like dependencies is still compared
+func onlyOptionalFieldsSetIn(expect, actual any) any {
+	e, a := reflect.ValueOf(expect), reflect.ValueOf(actual)
+	if e.Kind() != reflect.Struct || e.Type() != a.Type() {
+		return actual
+	}
+	value := reflect.New(a.Type()).Elem()
+	value.Set(a)
+	for i := 0; i < e.NumField(); i++ {
+		_, options, _ := strings.Cut(e.Type().Field(i).Tag.Get("yaml"), ",")
+		if !strings.Contains(options, "omitempty") {
+			continue
+		}
+		if unsetIn(e, i) {
+			value.Field(i).Set(e.Field(i))
+		}
+	}
+	return value.Interface()
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dependabot/cli/internal/model"
+)
+
+// ExpectationMismatchError is a call whose payload didn't match the expectation it was checked against
+type ExpectationMismatchError struct {
+	// Kind is the type of call, e.g. create_pull_request
+	Kind string
+	// Err describes the difference
+	Err error
+}
+
+func (e *ExpectationMismatchError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExpectationMismatchError) Unwrap() error {
+	return e.Err
+}
+
+// MissingExpectationError is a call that arrived after every expectation was used up
+type MissingExpectationError struct {
+	// Kind is the type of call, e.g. create_pull_request
+	Kind string
+}
+
+func (e *MissingExpectationError) Error() string {
+	return fmt.Sprintf("missing expectation for %s", e.Kind)
+}
+
+// UnmetExpectationError is an expectation that no call satisfied by the time the run completed
+type UnmetExpectationError struct {
+	Expectation model.Output
+}
+
+func (e *UnmetExpectationError) Error() string {
+	return fmt.Sprintf("expectation not met: %v\n%v", e.Expectation.Type, e.Expectation.Expect)
+}
+
+// UnexpectedTypeError is a call of a different type than the expectation at the cursor
+type UnexpectedTypeError struct {
+	// Expected is the type of the expectation, or empty if none of the unordered expectations could match
+	Expected string
+	// Actual is the type of call that was received
+	Actual string
+}
+
+func (e *UnexpectedTypeError) Error() string {
+	if e.Expected == "" {
+		return fmt.Sprintf("type was unexpected: no unordered expectation of type %v", e.Actual)
+	}
+	return fmt.Sprintf("type was unexpected: expected %v got %v", e.Expected, e.Actual)
+}
+
+// DecodeError is a call whose body couldn't be read or decoded, so it wasn't checked against the expectations
+type DecodeError struct {
+	// Kind is the type of call, e.g. create_pull_request
+	Kind string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// isExpectationError reports whether err is about the expectations, rather than the server or decoding
+func isExpectationError(err error) bool {
+	var mismatch *ExpectationMismatchError
+	var missing *MissingExpectationError
+	var unmet *UnmetExpectationError
+	var unexpectedType *UnexpectedTypeError
+	return errors.As(err, &mismatch) || errors.As(err, &missing) || errors.As(err, &unmet) || errors.As(err, &unexpectedType)
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dependabot/cli/internal/model"
+)
+
+func TestAPI_typedErrors(t *testing.T) {
+	expected := []model.Output{
+		{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}}},
+		{Type: "close_pull_request", Expect: model.UpdateWrapper{Data: model.ClosePullRequest{Reason: "up_to_date"}}},
+		{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}}},
+	}
+	api := NewAPI(expected, nil)
+	defer api.Stop()
+
+	for _, body := range []string{
+		`{"data":{"base-commit-sha":"5678"}}`,
+		`{"data":{"base-commit-sha":"1234"}}`,
+		`{"data":{"unknown":"value"}}`,
+	} {
+		request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(body))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+	api.Complete()
+
+	if len(api.Errors) != 4 {
+		t.Fatalf("expected 4 errors, got %v", api.Errors)
+	}
+
+	var mismatch *ExpectationMismatchError
+	if !errors.As(api.Errors[0], &mismatch) || mismatch.Kind != "mark_as_processed" {
+		t.Errorf("expected a mismatch, got %#v", api.Errors[0])
+	}
+	var unexpectedType *UnexpectedTypeError
+	if !errors.As(api.Errors[1], &unexpectedType) || unexpectedType.Expected != "close_pull_request" || unexpectedType.Actual != "mark_as_processed" {
+		t.Errorf("expected an unexpected type, got %#v", api.Errors[1])
+	}
+	var decodeErr *DecodeError
+	if !errors.As(api.Errors[2], &decodeErr) || decodeErr.Kind != "mark_as_processed" {
+		t.Errorf("expected a decode error, got %#v", api.Errors[2])
+	}
+	var unmet *UnmetExpectationError
+	if !errors.As(api.Errors[3], &unmet) || unmet.Expectation.Type != "mark_as_processed" {
+		t.Errorf("expected an unmet expectation, got %#v", api.Errors[3])
+	}
+
+	api.Reset(expected[:1])
+	for i := 0; i < 2; i++ {
+		request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(`{"data":{"base-commit-sha":"1234"}}`))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+	var missing *MissingExpectationError
+	if len(api.Errors) != 1 || !errors.As(api.Errors[0], &missing) || missing.Kind != "mark_as_processed" {
+		t.Errorf("expected a missing expectation, got %v", api.Errors)
+	}
+}
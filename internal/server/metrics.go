@@ -0,0 +1,73 @@
+package server
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WithMetrics registers Prometheus metrics for the API with the registry. All are labeled by the
+// kind of call, e.g. create_pull_request:
+//
+//   - dependabot_api_requests_total counts the calls received
+//   - dependabot_api_errors_total counts the calls that recorded an error
+//   - dependabot_api_request_duration_seconds is a histogram of the time spent handling a call
+//   - dependabot_api_request_body_bytes is a histogram of the size of the request bodies, unusually
+//     large ones such as an update_dependency_list of a huge monorepo often come before the updater runs out of memory
+func WithMetrics(registry prometheus.Registerer) APIOption {
+	return func(a *API) {
+		a.metrics = newAPIMetrics(registry)
+	}
+}
+
+type apiMetrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	bodySize *prometheus.HistogramVec
+}
+
+func newAPIMetrics(registry prometheus.Registerer) *apiMetrics {
+	m := &apiMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dependabot_api_requests_total",
+			Help: "Number of calls received by the fake Dependabot API.",
+		}, []string{"kind"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dependabot_api_errors_total",
+			Help: "Number of calls to the fake Dependabot API that recorded an error.",
+		}, []string{"kind"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "dependabot_api_request_duration_seconds",
+			Help:    "Time spent decoding and checking calls to the fake Dependabot API.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"kind"}),
+		bodySize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "dependabot_api_request_body_bytes",
+			Help:    "Size of the request bodies received by the fake Dependabot API.",
+			Buckets: []float64{1 << 10, 10 << 10, 100 << 10, 1 << 20, 10 << 20},
+		}, []string{"kind"}),
+	}
+	registry.MustRegister(m.requests, m.errors, m.duration, m.bodySize)
+	return m
+}
+
+// observe is a no-op when metrics aren't configured
+func (m *apiMetrics) observe(kind string, start time.Time, failed bool) {
+	if m == nil {
+		return
+	}
+	m.requests.WithLabelValues(kind).Inc()
+	if failed {
+		m.errors.WithLabelValues(kind).Inc()
+	}
+	m.duration.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+}
+
+// observeBodySize is a no-op when metrics aren't configured
+func (m *apiMetrics) observeBodySize(kind string, n int64) {
+	if m == nil {
+		return
+	}
+	m.bodySize.WithLabelValues(kind).Observe(float64(n))
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWithMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	api := NewAPI(nil, nil, WithMetrics(registry))
+	defer api.Stop()
+
+	for _, body := range []string{`{"data":{"base-commit-sha":"1234"}}`, `{"data":{"unknown":"value"}}`} {
+		request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(body))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	if count := testutil.ToFloat64(api.metrics.requests.WithLabelValues("mark_as_processed")); count != 2 {
+		t.Errorf("expected 2 requests, got %v", count)
+	}
+	if count := testutil.ToFloat64(api.metrics.errors.WithLabelValues("mark_as_processed")); count != 1 {
+		t.Errorf("expected 1 error, got %v", count)
+	}
+	if count := testutil.CollectAndCount(registry, "dependabot_api_request_duration_seconds"); count != 1 {
+		t.Errorf("expected a duration histogram, got %v", count)
+	}
+
+	want := `
+# HELP dependabot_api_request_body_bytes Size of the request bodies received by the fake Dependabot API.
+# TYPE dependabot_api_request_body_bytes histogram
+dependabot_api_request_body_bytes_bucket{kind="mark_as_processed",le="1024"} 2
+dependabot_api_request_body_bytes_bucket{kind="mark_as_processed",le="10240"} 2
+dependabot_api_request_body_bytes_bucket{kind="mark_as_processed",le="102400"} 2
+dependabot_api_request_body_bytes_bucket{kind="mark_as_processed",le="1.048576e+06"} 2
+dependabot_api_request_body_bytes_bucket{kind="mark_as_processed",le="1.048576e+07"} 2
+dependabot_api_request_body_bytes_bucket{kind="mark_as_processed",le="+Inf"} 2
+dependabot_api_request_body_bytes_sum{kind="mark_as_processed"} 63
+dependabot_api_request_body_bytes_count{kind="mark_as_processed"} 2
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(want), "dependabot_api_request_body_bytes"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// WithRequestLogging logs the method, URL, headers and body size of every request to the structured logger,
+// or slog's default logger if WithStructuredLogger isn't used. Authorization headers are redacted.
+func WithRequestLogging(enabled bool) APIOption {
+	return func(a *API) {
+		a.requestLogging = enabled
+	}
+}
+
+// WithAuthToken rejects requests that don't have an `Authorization: Bearer <token>` header with a 401,
+// recording the mismatch in Errors, to test the updater forwards the job token it was given.
+func WithAuthToken(token string) APIOption {
+	return func(a *API) {
+		a.authToken = token
+	}
+}
+
+// handler wraps the API in the middleware its options enable, outermost last
+func (a *API) handler() http.Handler {
+	var h http.Handler = a
+	if a.authToken != "" {
+		h = a.authMiddleware(h)
+	}
+	if a.requestLogging {
+		h = a.loggingMiddleware(h)
+	}
+	return h
+}
+
+// redactedHeaders are never logged since they hold credentials
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization"}
+
+func (a *API) loggingMiddleware(next http.Handler) http.Handler {
+	logger := a.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := &countingReader{r: r.Body}
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{body, r.Body}
+
+		next.ServeHTTP(w, r)
+
+		headers := make([]any, 0, len(r.Header))
+		for _, name := range sortedKeys(r.Header) {
+			value := strings.Join(r.Header[name], ", ")
+			if slices.Contains(redactedHeaders, name) {
+				value = "[REDACTED]"
+			}
+			headers = append(headers, slog.String(name, value))
+		}
+		logger.Info("fake API request",
+			"method", r.Method,
+			"url", r.URL.String(),
+			slog.Group("headers", headers...),
+			"body_size", body.n,
+		)
+	})
+}
+
+func (a *API) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(a.authToken)) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// the token is never put in the error since it ends up in the logs
+		err := fmt.Errorf("unauthorized request to %s: wrong Authorization token", r.URL.Path)
+		if header == "" {
+			err = fmt.Errorf("unauthorized request to %s: missing Authorization header", r.URL.Path)
+		}
+		a.mu.Lock()
+		a.pushError(err)
+		a.mu.Unlock()
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWithRequestLogging(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, nil))
+	api := NewAPI(nil, nil, WithStructuredLogger(logger), WithRequestLogging(true))
+	defer api.Stop()
+
+	body := `{"data":{"base-commit-sha":"1234"}}`
+	request, err := http.NewRequest("POST", api.URL()+"/update_jobs/1/mark_as_processed", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Authorization", "secret-token")
+	request.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	var entry struct {
+		Msg      string            `json:"msg"`
+		Method   string            `json:"method"`
+		URL      string            `json:"url"`
+		Headers  map[string]string `json:"headers"`
+		BodySize int               `json:"body_size"`
+	}
+	if err = json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a JSON log entry, got %q", out.String())
+	}
+	if entry.Msg != "fake API request" || entry.Method != "POST" || entry.URL != "/update_jobs/1/mark_as_processed" {
+		t.Errorf("expected the request to be logged, got %+v", entry)
+	}
+	if entry.Headers["Authorization"] != "[REDACTED]" || entry.Headers["Content-Type"] != "application/json" {
+		t.Errorf("expected the headers with authorization redacted, got %v", entry.Headers)
+	}
+	if entry.BodySize != len(body) {
+		t.Errorf("expected a body size of %d, got %d", len(body), entry.BodySize)
+	}
+	if strings.Contains(out.String(), "secret-token") {
+		t.Error("expected the token not to be logged")
+	}
+}
+
+func TestWithRequestLogging_disabled(t *testing.T) {
+	var out bytes.Buffer
+	api := NewAPI(nil, nil, WithStructuredLogger(slog.New(slog.NewJSONHandler(&out, nil))))
+	defer api.Stop()
+
+	resp, err := http.Post(api.URL()+"/update_jobs/1/mark_as_processed", "application/json", strings.NewReader(`{"data":{"base-commit-sha":"1234"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if out.Len() != 0 {
+		t.Errorf("expected nothing to be logged, got %q", out.String())
+	}
+}
+
+func TestWithAuthToken(t *testing.T) {
+	api := NewAPI(nil, nil, WithAuthToken("job-token"))
+	defer api.Stop()
+
+	for _, tc := range []struct {
+		name          string
+		authorization string
+		status        int
+		err           string
+	}{
+		{"correct token", "Bearer job-token", http.StatusOK, ""},
+		{"missing header", "", http.StatusUnauthorized, "unauthorized request to /update_jobs/1/mark_as_processed: missing Authorization header"},
+		{"wrong token", "Bearer other-token", http.StatusUnauthorized, "unauthorized request to /update_jobs/1/mark_as_processed: wrong Authorization token"},
+		{"not a bearer token", "token job-token", http.StatusUnauthorized, "unauthorized request to /update_jobs/1/mark_as_processed: wrong Authorization token"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			api.Reset(nil)
+			request, err := http.NewRequest("POST", api.URL()+"/update_jobs/1/mark_as_processed", strings.NewReader(`{"data":{"base-commit-sha":"1234"}}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.authorization != "" {
+				request.Header.Set("Authorization", tc.authorization)
+			}
+			resp, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, resp.StatusCode)
+			}
+			if tc.err == "" {
+				if len(api.Errors) != 0 {
+					t.Errorf("expected no errors, got %v", api.Errors)
+				}
+				if len(api.Actual.Output) != 1 {
+					t.Errorf("expected the request to be handled, got %v", api.Actual.Output)
+				}
+				return
+			}
+			if len(api.Errors) != 1 || api.Errors[0].Error() != tc.err {
+				t.Errorf("expected the error %q, got %v", tc.err, api.Errors)
+			}
+			if len(api.Actual.Output) != 0 {
+				t.Errorf("expected the request not to be handled, got %v", api.Actual.Output)
+			}
+		})
+	}
+}
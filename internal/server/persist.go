@@ -0,0 +1,95 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WithPersistOnSignal writes Actual to path as YAML if the process receives SIGINT or SIGTERM,
+// so the results of a run that's killed aren't lost. The signal is then raised again so it still
+// stops the process, or reaches whatever else is handling it.
+func WithPersistOnSignal(path string) APIOption {
+	return func(a *API) {
+		a.persistPath = path
+	}
+}
+
+func (a *API) handleSignals() {
+	signals, stop := make(chan os.Signal, 1), make(chan struct{})
+	a.signals, a.stopSignals = signals, stop
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			a.mu.RLock()
+			if err := a.persist(a.persistPath); err != nil {
+				log.Println(err)
+			}
+			a.mu.RUnlock()
+			// stop catching it first, otherwise this and every later signal is swallowed
+			signal.Stop(signals)
+			raise(sig)
+		case <-stop:
+		}
+	}()
+}
+
+// raise sends sig to this process, exiting when the platform can't, e.g. SIGINT on Windows
+func raise(sig os.Signal) {
+	process, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = process.Signal(sig)
+	}
+	if err != nil {
+		log.Printf("failed to raise %v again, exiting: %v", sig, err)
+		os.Exit(1)
+	}
+}
+
+func (a *API) stopHandlingSignals() {
+	if a.signals == nil {
+		return
+	}
+	signal.Stop(a.signals)
+	close(a.stopSignals)
+	a.signals = nil
+}
+
+func (a *API) persist(path string) error {
+	data, err := yaml.Marshal(a.Actual)
+	if err != nil {
+		return fmt.Errorf("failed to marshal actual scenario: %w", err)
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic writes to a temporary file and renames it over path so readers never see a partial write
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	// CreateTemp only allows the owner to read the file
+	if err = f.Chmod(0644); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
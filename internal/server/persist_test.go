@@ -0,0 +1,47 @@
+//go:build !windows
+
+package server
+
+import (
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWithPersistOnSignal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "actual.yml")
+	api := NewAPI(nil, nil, WithPersistOnSignal(path))
+	defer api.Stop()
+
+	request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(`{"data":{"base-commit-sha":"1234"}}`))
+	api.ServeHTTP(httptest.NewRecorder(), request)
+
+	// stands in for the default handler that would stop the process, so the signal raised again is seen
+	received := make(chan os.Signal, 2)
+	signal.Notify(received, syscall.SIGTERM)
+	defer signal.Stop(received)
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected SIGTERM to be raised again after persisting, got %d signals", i)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected the actual scenario to be written on SIGTERM: %v", err)
+	}
+	if !strings.Contains(string(data), "base-commit-sha: \"1234\"") {
+		t.Errorf("expected the actual scenario to be written, got %q", data)
+	}
+}
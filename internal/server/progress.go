@@ -0,0 +1,32 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WithProgress prints a line to out as each expectation is checked, e.g. `✓ create_pull_request #2`,
+// or the first line of the difference when it doesn't match. It shows how far a run got before it finishes.
+func WithProgress(out io.Writer) APIOption {
+	return func(a *API) {
+		a.progress = out
+	}
+}
+
+// reportProgress prints whether the expectation at index matched, the caller must hold the lock
+func (a *API) reportProgress(index int, kind string, err error) {
+	if a.progress == nil {
+		return
+	}
+	if err == nil {
+		_, _ = fmt.Fprintf(a.progress, "✓ %s #%d\n", kind, index+1)
+		return
+	}
+	// the first line is often a heading for the differences below it, so include the first of those
+	brief, rest, _ := strings.Cut(err.Error(), "\n")
+	if next, _, _ := strings.Cut(rest, "\n"); strings.HasSuffix(brief, ":") && next != "" {
+		brief += " " + next
+	}
+	_, _ = fmt.Fprintf(a.progress, "✗ %s #%d: %s\n", kind, index+1, brief)
+}
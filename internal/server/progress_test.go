@@ -0,0 +1,35 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dependabot/cli/internal/model"
+)
+
+func TestWithProgress(t *testing.T) {
+	markAsProcessed := func(sha string, unordered bool) model.Output {
+		return model.Output{Type: "mark_as_processed", Unordered: unordered, Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: sha}}}
+	}
+	var out bytes.Buffer
+	api := NewAPI([]model.Output{
+		markAsProcessed("1", false),
+		markAsProcessed("2", false),
+		markAsProcessed("3", true),
+		markAsProcessed("4", true),
+	}, nil, WithProgress(&out))
+	defer api.Stop()
+
+	for _, sha := range []string{"1", "x", "4", "3"} {
+		_ = api.InjectRequest("mark_as_processed", []byte(`{"data":{"base-commit-sha":"`+sha+`"}}`))
+	}
+
+	want := `✓ mark_as_processed #1
+✗ mark_as_processed #2: unexpected body for mark_as_processed: base-commit-sha: expected "2" got "x"
+✓ mark_as_processed #4
+✓ mark_as_processed #3
+`
+	if out.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, out.String())
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/dependabot/cli/internal/model"
+)
+
+type outputType struct {
+	decode  func(io.Reader, bodyDecoder) (any, error)
+	compare func(expect, actual any) error
+}
+
+var (
+	registryMu  sync.RWMutex
+	outputTypes = map[string]outputType{}
+	// outputKinds maps the payload type back to its kind for compare
+	outputKinds = map[reflect.Type]string{}
+)
+
+// RegisterOutputType adds a kind of call the API accepts in addition to the built-in ones, for forks of
+// the updater that send their own calls. The payload is decoded as T and expectations are checked with cmp.
+func RegisterOutputType[T any](kind string, cmp func(expect, actual T) error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	outputTypes[kind] = outputType{
+		decode: func(data io.Reader, decodeBody bodyDecoder) (any, error) {
+			return decode[T](data, decodeBody)
+		},
+		compare: func(expect, actual any) error {
+			actualT, ok := actual.(T)
+			if !ok {
+				return fmt.Errorf("type was unexpected: expected %v got %T", kind, actual)
+			}
+			return cmp(expect.(T), actualT)
+		},
+	}
+	outputKinds[reflect.TypeOf(*new(T))] = kind
+	model.RegisterOutputSchema(kind, reflect.TypeOf(*new(T)))
+}
+
+func registeredDecoder(kind string) (func(io.Reader, bodyDecoder) (any, error), bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	t, ok := outputTypes[kind]
+	return t.decode, ok
+}
+
+func registeredComparer(payload any) (func(expect, actual any) error, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	kind, ok := outputKinds[reflect.TypeOf(payload)]
+	if !ok {
+		return nil, false
+	}
+	return outputTypes[kind].compare, true
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dependabot/cli/internal/model"
+)
+
+type recordSecurityAdvisory struct {
+	Advisory string `yaml:"advisory"`
+}
+
+func TestRegisterOutputType(t *testing.T) {
+	RegisterOutputType("record_security_advisory", func(expect, actual recordSecurityAdvisory) error {
+		if expect != actual {
+			return fmt.Errorf("unexpected advisory %v", actual.Advisory)
+		}
+		return nil
+	})
+
+	api := NewAPI([]model.Output{{
+		Type:   "record_security_advisory",
+		Expect: model.UpdateWrapper{Data: map[string]any{"advisory": "GHSA-1234"}},
+	}}, nil)
+	defer api.Stop()
+
+	for _, advisory := range []string{"GHSA-1234", "GHSA-5678"} {
+		body := fmt.Sprintf(`{"data":{"advisory":%q}}`, advisory)
+		request := httptest.NewRequest("POST", "/update_jobs/1/record_security_advisory", strings.NewReader(body))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	if len(api.Errors) != 1 || !strings.Contains(api.Errors[0].Error(), "missing expectation") {
+		t.Errorf("expected the registered type to be decoded and compared, got %v", api.Errors)
+	}
+	if _, err := decodeWrapper("record_security_advisory", strings.NewReader(`{"data":{"unknown":"x"}}`), decodeYAML); err == nil {
+		t.Error("expected registered types to reject unknown fields")
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dependabot/cli/internal/model"
+)
+
+// WithReplayDir writes the raw body of every call that can be expected to dir as <seq>-<kind>.json, see ReplayFrom
+func WithReplayDir(dir string) APIOption {
+	return func(a *API) {
+		a.replayDir = dir
+	}
+}
+
+func (a *API) writeReplay(kind string, body []byte) {
+	a.replaySeq++
+	name := filepath.Join(a.replayDir, fmt.Sprintf("%04d-%s.json", a.replaySeq, kind))
+	if err := os.WriteFile(name, body, 0666); err != nil {
+		a.pushError(fmt.Errorf("failed to write replay file: %w", err))
+	}
+}
+
+// ReplayFrom reads the calls written by WithReplayDir back as expectations, in the order they were received.
+// Metrics and bodies that can't be decoded are skipped since the API never checks them against expectations.
+func ReplayFrom(dir string) ([]model.Output, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var outputs []model.Output
+	for _, name := range names {
+		_, kind, _ := strings.Cut(strings.TrimSuffix(filepath.Base(name), ".json"), "-")
+		if kind == "increment_metric" {
+			// metrics are counted rather than checked, so they can't be expected
+			continue
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read replay file: %w", err)
+		}
+		actual, err := decodeWrapper(kind, bytes.NewReader(data), decodeJSON)
+		if err != nil {
+			// the API rejected the call when it was recorded, so it isn't expected either, e.g. from an older recording
+			slog.Warn("skipping replay file that can't be decoded", "file", name, "error", err)
+			continue
+		}
+		outputs = append(outputs, model.Output{Type: kind, Expect: *actual})
+	}
+	return outputs, nil
+}
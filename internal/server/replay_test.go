@@ -0,0 +1,71 @@
+package server
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	api := NewAPI(nil, nil, WithReplayDir(dir))
+	defer api.Stop()
+
+	calls := map[string]string{
+		"update_dependency_list": `{"data":{"dependencies":[],"dependency_files":["/go.mod"]}}`,
+		"increment_metric":       `{"data":{"metric":"updater.started","tags":{}}}`,
+		"mark_as_processed":      `{"data":{"base-commit-sha":"1234"}}`,
+		"close_pull_request":     `{"data":`,
+	}
+	order := []string{"update_dependency_list", "increment_metric", "close_pull_request", "mark_as_processed"}
+	for _, kind := range order {
+		request := httptest.NewRequest("POST", "/update_jobs/1/"+kind, strings.NewReader(calls[kind]))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Errorf("expected the metric and the undecodable body not to be written, got %v", names)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "0002-mark_as_processed.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != calls["mark_as_processed"] {
+		t.Errorf("expected the raw body to be written, got %q", data)
+	}
+
+	// as written by an older version that kept them
+	for name, body := range map[string]string{
+		"0003-increment_metric.json":   calls["increment_metric"],
+		"0004-close_pull_request.json": calls["close_pull_request"],
+	} {
+		if err = os.WriteFile(filepath.Join(dir, name), []byte(body), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := ReplayFrom(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expected) != 2 {
+		t.Errorf("expected the metric and the undecodable body to be skipped, got %v", expected)
+	}
+	replay := NewAPI(expected, nil)
+	defer replay.Stop()
+	for _, kind := range []string{"update_dependency_list", "increment_metric", "mark_as_processed"} {
+		request := httptest.NewRequest("POST", "/update_jobs/1/"+kind, strings.NewReader(calls[kind]))
+		replay.ServeHTTP(httptest.NewRecorder(), request)
+	}
+	replay.Complete()
+
+	if len(replay.Errors) != 0 {
+		t.Errorf("expected the replay to match, got %v", replay.Errors)
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// response is what a request is answered with, the zero value is an empty 200
+type response struct {
+	status  int
+	headers http.Header
+	body    []byte
+}
+
+func (r response) write(w http.ResponseWriter) {
+	if r.status == 0 {
+		return
+	}
+	for name, values := range r.headers {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(r.status)
+	_, _ = w.Write(r.body)
+}
+
+// errorResponse reports err to the updater as JSON, like the real API
+func errorResponse(status int, err error) response {
+	body, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return response{
+		status:  status,
+		headers: http.Header{"Content-Type": {"application/json"}},
+		body:    append(body, '\n'),
+	}
+}
+
+// RespondWith queues a response for the next request of the given kind, e.g. a 429 to test that the
+// updater retries. The request is answered with status and body without being decoded or checked
+// against the expectations. Responses queued for the same kind are returned in order.
+func (a *API) RespondWith(kind string, status int, body []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.responses == nil {
+		a.responses = map[string][]response{}
+	}
+	a.responses[kind] = append(a.responses[kind], response{status: status, body: body})
+}
+
+// SetResponse makes every request of the given kind be answered with status, headers and body instead of
+// an empty 200, to test how the updater handles the API's response. Unlike RespondWith the request is still
+// decoded and checked against the expectations, and requests that fail to decode still get an error status.
+func (a *API) SetResponse(kind string, status int, headers http.Header, body []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.fixedResponses == nil {
+		a.fixedResponses = map[string]response{}
+	}
+	a.fixedResponses[kind] = response{status: status, headers: headers.Clone(), body: body}
+}
+
+// nextQueued returns the next queued response for kind, reporting whether there was one
+func (a *API) nextQueued(kind string) (response, bool) {
+	queue := a.responses[kind]
+	if len(queue) == 0 {
+		return response{}, false
+	}
+	a.responses[kind] = queue[1:]
+	return queue[0], true
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dependabot/cli/internal/model"
+	"golang.org/x/mod/semver"
+)
+
+// WithSemverEquivalence treats dependency versions and requirements as equal when they're semantically
+// the same, e.g. 1.2 and 1.2.0, or ^1.2 and ^1.2.0, instead of requiring the same string. It applies to
+// the dependencies of create_pull_request and update_dependency_list, update_pull_request only has their names.
+func WithSemverEquivalence() APIOption {
+	return func(a *API) {
+		a.semverEquivalence = true
+	}
+}
+
+// alignEquivalentVersions returns a copy of actual with each version and requirement that's semantically
+// equal to the expected one replaced by the expected string, so the comparison only fails on real differences.
+// Other kinds of call are returned as they are.
+func alignEquivalentVersions(expect, actual any) any {
+	switch expect := expect.(type) {
+	case model.CreatePullRequest:
+		if actual, ok := actual.(model.CreatePullRequest); ok {
+			actual.Dependencies = alignDependencies(expect.Dependencies, actual.Dependencies)
+			return actual
+		}
+	case model.UpdateDependencyList:
+		if actual, ok := actual.(model.UpdateDependencyList); ok {
+			actual.Dependencies = alignDependencies(expect.Dependencies, actual.Dependencies)
+			return actual
+		}
+	}
+	return actual
+}
+
+// alignDependencies pairs the dependencies by name and ecosystem, so the order they're listed in doesn't matter
+func alignDependencies(expect, actual []model.Dependency) []model.Dependency {
+	actual = slices.Clone(actual)
+	paired := make([]bool, len(expect))
+	for i := range actual {
+		dep := &actual[i]
+		j := -1
+		for k := range expect {
+			if !paired[k] && expect[k].Name == dep.Name && expect[k].Ecosystem == dep.Ecosystem {
+				j = k
+				break
+			}
+		}
+		if j < 0 {
+			continue
+		}
+		paired[j] = true
+		expected := expect[j]
+		if dep.Version != nil && expected.Version != nil && semverEqual(*dep.Version, *expected.Version) {
+			dep.Version = expected.Version
+		}
+		if semverEqual(dep.PreviousVersion, expected.PreviousVersion) {
+			dep.PreviousVersion = expected.PreviousVersion
+		}
+		dep.Requirements = alignRequirements(expected.Requirements, dep.Requirements)
+		if dep.PreviousRequirements != nil && expected.PreviousRequirements != nil {
+			previous := alignRequirements(*expected.PreviousRequirements, *dep.PreviousRequirements)
+			dep.PreviousRequirements = &previous
+		}
+	}
+	return actual
+}
+
+// alignRequirements pairs the requirements by file, in order when a file has several
+func alignRequirements(expect, actual []model.Requirement) []model.Requirement {
+	actual = slices.Clone(actual)
+	paired := make([]bool, len(expect))
+	for i := range actual {
+		req := &actual[i]
+		j := -1
+		for k := range expect {
+			if !paired[k] && expect[k].File == req.File {
+				j = k
+				break
+			}
+		}
+		if j < 0 {
+			continue
+		}
+		paired[j] = true
+		expected := expect[j]
+		if req.Requirement != nil && expected.Requirement != nil && requirementEqual(*req.Requirement, *expected.Requirement) {
+			req.Requirement = expected.Requirement
+		}
+		if semverEqual(req.Version, expected.Version) {
+			req.Version = expected.Version
+		}
+		if semverEqual(req.PreviousVersion, expected.PreviousVersion) {
+			req.PreviousVersion = expected.PreviousVersion
+		}
+	}
+	return actual
+}
+
+// semverEqual reports whether a and b are the same semantic version, ignoring build metadata.
+// Versions that aren't valid semver are only equal if the strings are.
+func semverEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+	va, vb := canonicalVersion(a), canonicalVersion(b)
+	if !semver.IsValid(va) || !semver.IsValid(vb) {
+		return false
+	}
+	return semver.Compare(va, vb) == 0
+}
+
+func canonicalVersion(v string) string {
+	if !strings.HasPrefix(v, "v") {
+		v = "v" + v
+	}
+	return v
+}
+
+// comparatorPattern matches one comparison in a requirement, e.g. ^1.2.0, ~> 1.2 or >=1.0
+var comparatorPattern = regexp.MustCompile(`^(\^|~>|~=|~|>=|<=|>|<|==|=|!=)?\s*v?(\d+(?:\.\d+){0,2}(?:[-+][0-9A-Za-z.+-]*)?)$`)
+
+// requirementEqual reports whether a and b allow the same versions, e.g. ^1.2 and ^1.2.0, but not ~> 1.2 and
+// ~> 1.2.0 or 1.2 and 1.2.0 since the number of parts changes the range. Alternatives are separated by || and
+// the comparisons in each by commas or spaces. Requirements it can't parse are only equal if the strings are.
+func requirementEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ra, okA := parseRequirement(a)
+	rb, okB := parseRequirement(b)
+	return okA && okB && slices.EqualFunc(ra, rb, func(x, y []versionBounds) bool {
+		return slices.Equal(x, y)
+	})
+}
+
+// versionBounds is the range of versions one comparison allows, as canonical semver so equal bounds compare equal
+type versionBounds struct {
+	op, lower, upper string
+}
+
+func parseRequirement(requirement string) ([][]versionBounds, bool) {
+	var alternatives [][]versionBounds
+	for _, alternative := range strings.Split(requirement, "||") {
+		var comparisons []versionBounds
+		for _, comparison := range splitComparisons(alternative) {
+			bounds, ok := parseComparison(comparison)
+			if !ok {
+				return nil, false
+			}
+			comparisons = append(comparisons, bounds)
+		}
+		if len(comparisons) == 0 {
+			return nil, false
+		}
+		alternatives = append(alternatives, comparisons)
+	}
+	return alternatives, true
+}
+
+// splitComparisons splits on commas and spaces, keeping an operator with the version after it, e.g. ">= 1.0, < 2"
+func splitComparisons(alternative string) []string {
+	var comparisons []string
+	var operator string
+	for _, field := range strings.FieldsFunc(alternative, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if strings.Trim(field, "^~><=!") == "" {
+			operator += field
+			continue
+		}
+		comparisons = append(comparisons, operator+field)
+		operator = ""
+	}
+	if operator != "" {
+		// an operator without a version can't be parsed
+		comparisons = append(comparisons, operator)
+	}
+	return comparisons
+}
+
+func parseComparison(comparison string) (versionBounds, bool) {
+	m := comparatorPattern.FindStringSubmatch(comparison)
+	if m == nil {
+		return versionBounds{}, false
+	}
+	op, version := m[1], m[2]
+	release, suffix := version, ""
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		release, suffix = version[:i], version[i:]
+	}
+	var parts []int
+	for _, part := range strings.Split(release, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return versionBounds{}, false
+		}
+		parts = append(parts, n)
+	}
+	lower := canonicalVersion(formatParts(parts) + suffix)
+	if !semver.IsValid(lower) {
+		return versionBounds{}, false
+	}
+	lower = semver.Canonical(lower)
+	// a partial version stands for every version it's a prefix of, e.g. 1.2 is 1.2.x
+	partial := len(parts) < 3 && suffix == ""
+
+	switch op {
+	case "", "=":
+		if partial {
+			return versionBounds{op: "range", lower: lower, upper: bump(parts, len(parts)-1)}, true
+		}
+		return versionBounds{op: "=", lower: lower}, true
+	case "==":
+		// PEP 440 pads a version with zeros to compare it, so ==1.2 is 1.2.0
+		return versionBounds{op: "=", lower: lower}, true
+	case "<=":
+		if partial {
+			// <=1.2 allows 1.2.5, so it's <1.3.0
+			return versionBounds{op: "<", lower: bump(parts, len(parts)-1)}, true
+		}
+		return versionBounds{op: op, lower: lower}, true
+	case ">":
+		if partial {
+			// >1.2 doesn't allow 1.2.5, so it's >=1.3.0
+			return versionBounds{op: ">=", lower: bump(parts, len(parts)-1)}, true
+		}
+		return versionBounds{op: op, lower: lower}, true
+	case "^":
+		// the first part that isn't zero can't change, or the last one given if they all are
+		i := slices.IndexFunc(parts, func(n int) bool { return n != 0 })
+		if i < 0 {
+			i = len(parts) - 1
+		}
+		return versionBounds{op: "range", lower: lower, upper: bump(parts, i)}, true
+	case "~":
+		// npm allows patch changes if the minor version is given, otherwise minor changes
+		return versionBounds{op: "range", lower: lower, upper: bump(parts, min(1, len(parts)-1))}, true
+	case "~>", "~=":
+		// the last part given can change
+		return versionBounds{op: "range", lower: lower, upper: bump(parts, max(0, len(parts)-2))}, true
+	default:
+		return versionBounds{op: op, lower: lower}, true
+	}
+}
+
+// bump returns the canonical version with parts[i] incremented and the parts after it dropped
+func bump(parts []int, i int) string {
+	bumped := slices.Clone(parts[:i+1])
+	bumped[i]++
+	return semver.Canonical(canonicalVersion(formatParts(bumped)))
+}
+
+func formatParts(parts []int) string {
+	s := make([]string, len(parts))
+	for i, n := range parts {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ".")
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dependabot/cli/internal/model"
+)
+
+func Test_requirementEqual(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"^1.2.0", "^1.2.0", true},
+		{"^1.2", "^1.2.0", true},
+		{"^1.2.0", "^1.3.0", false},
+		{"^0.2", "^0.2.0", true},
+		{"^0.0", "^0.0.0", false},
+		{"~1.2", "~1.2.0", true},
+		{"~1", "~1.0", false},
+		{"~> 1.2", "~>1.2", true},
+		{"~> 1.2", "~> 1.2.0", false},
+		{"~= 2.1", "~=2.1", true},
+		{">= 1.0, < 2", ">=1.0.0 <2.0.0", true},
+		{">= 1.0, < 2", ">= 1.0, < 3", false},
+		{"^1.2 || ^2.0", "^1.2.0 || ^2.0.0", true},
+		{"1.2", "1.2.0", false},
+		{"1.2", "=1.2.0", false},
+		{"1.2", "=1.2", true},
+		{"1.2", "~1.2.0", true},
+		{"1.2", "^1.2", false},
+		{"==1.2", "==1.2.0", true},
+		{"<=1.2", "<=1.2.0", false},
+		{"<=1.2", "<1.3.0", true},
+		{">1.2", ">1.2.0", false},
+		{">1.2", ">=1.3", true},
+		{"<1.2", "<1.2.0", true},
+		{">=1.2", ">=1.2.0", true},
+		{"v1.2.0", "1.2.0", true},
+		{"v1.2.0", "1.2", false},
+		{"1.2.0 - 2.0.0", "1.2 - 2", false},
+		{"latest", "latest", true},
+		{"latest", "1.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := requirementEqual(tt.a, tt.b); got != tt.want {
+			t.Errorf("requirementEqual(%q, %q) = %v, expected %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func Test_alignEquivalentVersions(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	expect := model.CreatePullRequest{Dependencies: []model.Dependency{
+		{Name: "a", Version: ptr("1.2.0"), Requirements: []model.Requirement{{File: "package.json", Requirement: ptr("^1.2.0")}}},
+		{Name: "b", Version: ptr("2.0.0"), PreviousRequirements: &[]model.Requirement{{File: "package.json", Requirement: ptr("~> 1.0")}}},
+	}}
+
+	t.Run("pairs dependencies by name", func(t *testing.T) {
+		actual := model.CreatePullRequest{Dependencies: []model.Dependency{
+			{Name: "b", Version: ptr("2.0"), PreviousRequirements: &[]model.Requirement{{File: "package.json", Requirement: ptr("~>1.0")}}},
+			{Name: "a", Version: ptr("1.2"), Requirements: []model.Requirement{{File: "package.json", Requirement: ptr("^1.2")}}},
+		}}
+		aligned := alignEquivalentVersions(expect, actual).(model.CreatePullRequest)
+		want := model.CreatePullRequest{Dependencies: []model.Dependency{expect.Dependencies[1], expect.Dependencies[0]}}
+		if !reflect.DeepEqual(aligned, want) {
+			t.Errorf("expected every version and requirement to be aligned, got %+v", aligned)
+		}
+		if *actual.Dependencies[1].Requirements[0].Requirement != "^1.2" {
+			t.Error("expected the actual call to be left alone")
+		}
+	})
+
+	t.Run("keeps differences", func(t *testing.T) {
+		actual := model.CreatePullRequest{Dependencies: []model.Dependency{
+			{Name: "a", Version: ptr("1.3"), Requirements: []model.Requirement{{File: "package.json", Requirement: ptr("^1.3")}}},
+		}}
+		aligned := alignEquivalentVersions(expect, actual).(model.CreatePullRequest)
+		if !reflect.DeepEqual(aligned, actual) {
+			t.Errorf("expected different versions to be kept, got %+v", aligned)
+		}
+	})
+
+	t.Run("update_dependency_list", func(t *testing.T) {
+		list := model.UpdateDependencyList{Dependencies: expect.Dependencies[:1]}
+		actual := model.UpdateDependencyList{Dependencies: []model.Dependency{
+			{Name: "a", Version: ptr("1.2"), Requirements: []model.Requirement{{File: "package.json", Requirement: ptr("^1.2")}}},
+		}}
+		if aligned := alignEquivalentVersions(list, actual); !reflect.DeepEqual(aligned, list) {
+			t.Errorf("expected the list to be aligned, got %+v", aligned)
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package server
+
+import "os"
+
+// evaluateSkipIf marks the expectations whose skip-if condition is true as met, so they're passed over
+// like an unordered expectation that already matched. The conditions only depend on the environment,
+// so they're evaluated once per run. The caller must hold the lock.
+func (a *API) evaluateSkipIf() {
+	if a.skipEvaluated {
+		return
+	}
+	a.skipEvaluated = true
+	environ := os.Environ()
+	for i := range a.Expectations {
+		skip, err := a.Expectations[i].Skipped(environ)
+		if err != nil {
+			// checked as usual, so a typo in the condition doesn't hide a failure
+			a.pushError(err)
+			continue
+		}
+		if !skip {
+			continue
+		}
+		if a.matched == nil {
+			a.matched = make([]bool, len(a.Expectations))
+		}
+		a.matched[i] = true
+		a.met++
+	}
+	for a.matched != nil && a.cursor < len(a.Expectations) && a.matched[a.cursor] {
+		a.cursor++
+	}
+	a.checkDone()
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dependabot/cli/internal/model"
+)
+
+func TestAPI_skipIf(t *testing.T) {
+	markAsProcessed := func(sha, skipIf string) model.Output {
+		return model.Output{
+			Type:   "mark_as_processed",
+			Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: sha}},
+			SkipIf: skipIf,
+		}
+	}
+	t.Setenv("REGISTRY_URL", "")
+
+	t.Run("skipped expectations would have failed", func(t *testing.T) {
+		api := NewAPI([]model.Output{
+			markAsProcessed("private", "not .REGISTRY_URL"),
+			markAsProcessed("1", ""),
+			markAsProcessed("private", "not .REGISTRY_URL"),
+			markAsProcessed("2", ""),
+			markAsProcessed("private", "not .REGISTRY_URL"),
+		}, nil)
+		defer api.Stop()
+
+		for _, sha := range []string{"1", "2"} {
+			if err := api.InjectRequest("mark_as_processed", []byte(`{"data":{"base-commit-sha":"`+sha+`"}}`)); err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		}
+		api.MustComplete(t)
+		if !strings.Contains(api.Summary(), "expectations met: 5 of 5") {
+			t.Errorf("expected skipped expectations to count as met, got:\n%s", api.Summary())
+		}
+	})
+
+	t.Run("checked when the condition is false", func(t *testing.T) {
+		t.Setenv("REGISTRY_URL", "https://npm.example.com")
+		api := NewAPI([]model.Output{markAsProcessed("private", "not .REGISTRY_URL")}, nil)
+		defer api.Stop()
+
+		err := api.InjectRequest("mark_as_processed", []byte(`{"data":{"base-commit-sha":"1"}}`))
+		if err == nil || !strings.Contains(err.Error(), "private") {
+			t.Errorf("expected a mismatch, got %v", err)
+		}
+	})
+
+	t.Run("skipped in an unordered run", func(t *testing.T) {
+		skipped := markAsProcessed("private", "not .REGISTRY_URL")
+		skipped.Unordered = true
+		other := markAsProcessed("1", "")
+		other.Unordered = true
+		api := NewAPI([]model.Output{skipped, other}, nil)
+		defer api.Stop()
+
+		if err := api.InjectRequest("mark_as_processed", []byte(`{"data":{"base-commit-sha":"1"}}`)); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+		api.MustComplete(t)
+	})
+
+	t.Run("invalid condition", func(t *testing.T) {
+		api := NewAPI([]model.Output{markAsProcessed("1", "eq .REGISTRY_URL")}, nil)
+		defer api.Stop()
+
+		err := api.InjectRequest("mark_as_processed", []byte(`{"data":{"base-commit-sha":"1"}}`))
+		if err == nil || !strings.Contains(err.Error(), "invalid skip-if") {
+			t.Errorf("expected an invalid skip-if error, got %v", err)
+		}
+	})
+}
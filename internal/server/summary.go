@@ -0,0 +1,41 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Summary describes the run for test output or CI logs: how many calls were received, how many expectations
+// were met, which weren't, and every error. It can be called before Complete to see how far a run has got,
+// in which case the expectations not reached yet are listed as unmet.
+func (a *API) Summary() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "calls received: %d\n", len(a.callLog))
+	_, _ = fmt.Fprintf(&b, "expectations met: %d of %d\n", a.met, len(a.Expectations))
+	if unmet := a.unmetExpectations(); len(unmet) > 0 {
+		b.WriteString("unmet expectations:\n")
+		for _, expect := range unmet {
+			_, _ = fmt.Fprintf(&b, "  %s\n", expect.Type)
+		}
+	}
+
+	var errs []error
+	for _, err := range a.Errors {
+		// Complete adds an error for each unmet expectation, which are already listed
+		var unmetErr *UnmetExpectationError
+		if !errors.As(err, &unmetErr) {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		_, _ = fmt.Fprintf(&b, "errors: %d\n", len(errs))
+		for _, err := range errs {
+			_, _ = fmt.Fprintf(&b, "  - %s\n", strings.ReplaceAll(err.Error(), "\n", "\n    "))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/dependabot/cli/internal/model"
+)
+
+func TestAPI_Summary(t *testing.T) {
+	api := NewAPI([]model.Output{
+		{Type: "update_dependency_list", Expect: model.UpdateWrapper{Data: model.UpdateDependencyList{
+			Dependencies: []model.Dependency{}, DependencyFiles: []string{"/go.mod"},
+		}}},
+		{Type: "create_pull_request", Expect: model.UpdateWrapper{Data: model.CreatePullRequest{}}},
+		{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}}},
+	}, nil)
+	defer api.Stop()
+
+	want := `calls received: 0
+expectations met: 0 of 3
+unmet expectations:
+  update_dependency_list
+  create_pull_request
+  mark_as_processed
+`
+	if got := api.Summary(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	_ = api.InjectRequest("update_dependency_list", []byte(`{"data":{"dependencies":[],"dependency_files":["/go.mod"]}}`))
+	_ = api.InjectRequest("mark_as_processed", []byte(`{"data":{"base-commit-sha":"1234"}}`))
+
+	want = `calls received: 2
+expectations met: 1 of 3
+unmet expectations:
+  mark_as_processed
+errors: 1
+  - type was unexpected: expected create_pull_request got mark_as_processed
+`
+	if got := api.Summary(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	// the unmet expectation errors Complete adds aren't listed twice
+	api.Complete()
+	if got := api.Summary(); got != want {
+		t.Errorf("expected the same summary after Complete:\n%s\ngot:\n%s", want, got)
+	}
+}
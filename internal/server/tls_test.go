@@ -0,0 +1,36 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWithTLS(t *testing.T) {
+	api := NewAPI(nil, nil, WithTLS(nil, nil))
+	defer api.Stop()
+
+	cert, err := x509.ParseCertificate(api.CACert())
+	if err != nil {
+		t.Fatalf("expected a self-signed certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+	}}
+
+	url := fmt.Sprintf("https://127.0.0.1:%d/update_jobs/1/mark_as_processed", api.Port())
+	resp, err := client.Post(url, "application/json", strings.NewReader(`{"data":{"base-commit-sha":"1234"}}`))
+	if err != nil {
+		t.Fatalf("expected the request to succeed over TLS: %v", err)
+	}
+	resp.Body.Close()
+
+	if api.Snapshot().Input.Job.Source.Commit != "1234" {
+		t.Errorf("expected the call to be recorded")
+	}
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// WithTracer starts a span named after the kind of each call the API handles, e.g. create_pull_request.
+// The span has the call's sequence number, matching APICall.Seq, and records any error the call caused.
+func WithTracer(tracer trace.Tracer) APIOption {
+	return func(a *API) {
+		a.tracer = tracer
+	}
+}
+
+// defaultTracer does nothing, so there's no cost when tracing isn't configured
+var defaultTracer trace.Tracer = noop.NewTracerProvider().Tracer("")
+
+// endSpan finishes the span for a call once it's been handled, the caller must hold the lock
+func (a *API) endSpan(span trace.Span, callCount, errorCount int) {
+	if len(a.callLog) > callCount {
+		span.SetAttributes(attribute.Int("dependabot.api.seq", a.callLog[len(a.callLog)-1].Seq))
+	}
+	if len(a.Errors) > errorCount {
+		err := a.Errors[len(a.Errors)-1]
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dependabot/cli/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type recordingTracer struct {
+	noop.Tracer
+	spans []*recordingSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, _ ...trace.SpanStartOption) (context.Context, trace.Span) {
+	span := &recordingSpan{name: name}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+type recordingSpan struct {
+	noop.Span
+	name       string
+	attributes []attribute.KeyValue
+	errors     []error
+	status     codes.Code
+	ended      bool
+}
+
+func (s *recordingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.attributes = append(s.attributes, kv...)
+}
+
+func (s *recordingSpan) RecordError(err error, _ ...trace.EventOption) {
+	s.errors = append(s.errors, err)
+}
+
+func (s *recordingSpan) SetStatus(code codes.Code, _ string) {
+	s.status = code
+}
+
+func (s *recordingSpan) End(...trace.SpanEndOption) {
+	s.ended = true
+}
+
+func TestWithTracer(t *testing.T) {
+	expected := []model.Output{
+		{Type: "mark_as_processed", Expect: model.UpdateWrapper{Data: model.MarkAsProcessed{BaseCommitSha: "1234"}}},
+	}
+	tracer := &recordingTracer{}
+	api := NewAPI(expected, nil, WithTracer(tracer))
+	defer api.Stop()
+
+	for _, sha := range []string{"1234", "5678"} {
+		body := `{"data":{"base-commit-sha":"` + sha + `"}}`
+		request := httptest.NewRequest("POST", "/update_jobs/1/mark_as_processed", strings.NewReader(body))
+		api.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("expected a span per call, got %d", len(tracer.spans))
+	}
+	for i, span := range tracer.spans {
+		if span.name != "mark_as_processed" || !span.ended {
+			t.Errorf("expected an ended span named after the kind, got %+v", span)
+		}
+		want := attribute.Int("dependabot.api.seq", i+1)
+		if len(span.attributes) != 1 || span.attributes[0] != want {
+			t.Errorf("expected the sequence number %v, got %v", want, span.attributes)
+		}
+	}
+	if tracer.spans[0].status != codes.Unset || len(tracer.spans[0].errors) != 0 {
+		t.Errorf("expected the first call to succeed, got %+v", tracer.spans[0])
+	}
+	if tracer.spans[1].status != codes.Error || len(tracer.spans[1].errors) != 1 {
+		t.Errorf("expected the second call to record its error, got %+v", tracer.spans[1])
+	}
+}